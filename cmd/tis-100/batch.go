@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+)
+
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles per test")
+	seeds := fs.Int("seeds", 1, "number of seeds the puzzle is loaded with, starting from 1")
+	workers := fs.Int("workers", 0, "number of solutions run at once, 0 uses every CPU")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 batch [-cycles n] [-seeds n] [-workers n] [-trusted] puzzle.lua|puzzle.json|puzzle.yaml solution.tis|solutions-dir...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 || *seeds < 1 {
+		fs.Usage()
+		return errors.New("batch needs a puzzle, at least one solution and at least one seed")
+	}
+
+	solutions, err := solutionFiles(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	jobs := make([]runner.Job, 0, *seeds*len(solutions))
+	for seed := int64(1); seed <= int64(*seeds); seed++ {
+		puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: seed})
+		if err != nil {
+			return fmt.Errorf("seed %d: %w", seed, err)
+		}
+		defer puzzle.Close()
+		for _, solution := range solutions {
+			code, err := parser.FetchCode(solution)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, runner.Job{Puzzle: puzzle, Seed: seed, Solution: solution, Code: *code})
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	results, err := runner.RunBatch(ctx, jobs, *workers, *maxCycles)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, res := range results {
+		switch {
+		case res.Err != nil:
+			fmt.Fprintf(w, "%s\t%d\tERROR\t%s\n", res.Solution, res.Seed, res.Err)
+		case res.Passed:
+			fmt.Fprintf(
+				w, "%s\t%d\tPASSED\t%d CYCLES / %d NODES / %d INSTR\n",
+				res.Solution, res.Seed, res.Score.Cycles, res.Score.Nodes, res.Score.Instructions,
+			)
+		default:
+			fmt.Fprintf(w, "%s\t%d\tFAILED\t%s\n", res.Solution, res.Seed, failedReason(res.Results))
+		}
+		failed = failed || !res.Passed
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	if failed {
+		return errors.New("some solutions failed")
+	}
+	return nil
+}
+
+// solutionFiles expands directories of the given paths into the .tis files
+// they contain. Files named directly are kept whatever their extension.
+func solutionFiles(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == ".tis" {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+	return files, nil
+}
+
+func failedReason(results []runner.TestResult) string {
+	for _, res := range results {
+		if !res.Passed {
+			return fmt.Sprintf("%s: %s", res.Name, res.Reason)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/FranChesK0/tis-100/internal/compiler"
+	"github.com/FranChesK0/tis-100/internal/parser"
+)
+
+func runCompile(args []string) error {
+	fs := flag.NewFlagSet("compile", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of math.random, 0 picks a random one")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	output := fs.String("o", "", "file to write the solution to instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 compile [-seed n] [-trusted] [-o out.tis] puzzle.lua|puzzle.json|puzzle.yaml program")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("compile needs a puzzle and a program")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+	src, err := os.ReadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	code, err := compiler.Compile(puzzle, string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(1), err)
+	}
+
+	return writeOutput(*output, func(w io.Writer) error {
+		return parser.WriteCode(w, code)
+	})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/FranChesK0/tis-100/internal/parser"
+)
+
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of math.random, 0 picks a random one")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	output := fs.String("o", "", "write the puzzle to a file instead of stdout, in YAML if it ends with .yaml or .yml")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 export [-seed n] [-trusted] [-o puzzle.json|puzzle.yaml] puzzle.lua")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("export needs a puzzle file")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+
+	export := parser.ExportPuzzle
+	if ext := filepath.Ext(*output); ext == ".yaml" || ext == ".yml" {
+		export = parser.ExportYAMLPuzzle
+	}
+	return writeOutput(*output, func(w io.Writer) error {
+		return export(w, puzzle)
+	})
+}
+
+// writeOutput calls write with the file at path, or with stdout if path is
+// empty. Errors of closing the file are returned as well, as they may mean the
+// data never reached it.
+func writeOutput(path string, write func(w io.Writer) error) (err error) {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create file with name %s: %w", path, err)
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("unable to close file with name %s: %w", path, closeErr)
+		}
+	}()
+	return write(file)
+}
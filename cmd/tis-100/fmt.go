@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/format"
+)
+
+func runFmt(args []string) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := fs.Bool("check", false, "list files that are not formatted and fail if there are any")
+	write := fs.Bool("w", false, "write the formatted code back to the files")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 fmt [-check] [-w] solution.tis|solutions-dir...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || (*check && *write) {
+		fs.Usage()
+		return errors.New("fmt needs at least one solution and takes either -check or -w")
+	}
+
+	files, err := solutionFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	unformatted := 0
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".tis") {
+			continue
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(src, formatted) {
+				fmt.Println(file)
+				unformatted++
+			}
+		case *write:
+			if !bytes.Equal(src, formatted) {
+				if err := os.WriteFile(file, formatted, 0o644); err != nil {
+					return err
+				}
+			}
+		default:
+			if _, err := os.Stdout.Write(formatted); err != nil {
+				return err
+			}
+		}
+	}
+
+	if unformatted > 0 {
+		return fmt.Errorf("%d files are not formatted", unformatted)
+	}
+	return nil
+}
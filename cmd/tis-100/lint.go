@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/FranChesK0/tis-100/internal/analyzer"
+	"github.com/FranChesK0/tis-100/internal/parser"
+)
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed of math.random, 0 picks a random one")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 lint [-seed n] [-trusted] puzzle.lua|puzzle.json|puzzle.yaml solution.tis")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("lint needs a puzzle and a solution file")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+	code, err := parser.FetchCode(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diags, err := analyzer.Analyze(puzzle, *code)
+	if err != nil {
+		return err
+	}
+	for _, diag := range diags {
+		fmt.Println(diag)
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("found %d warnings", len(diags))
+	}
+	return nil
+}
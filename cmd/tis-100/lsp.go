@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FranChesK0/tis-100/internal/lsp"
+)
+
+func runLSP(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 lsp")
+		fmt.Fprintln(fs.Output(), "runs a language server for solution files over stdin and stdout")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errors.New("lsp takes no arguments")
+	}
+	return lsp.Serve(os.Stdin, os.Stdout)
+}
@@ -1,15 +1,65 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/FranChesK0/tis-100/internal/tui"
 )
 
 func main() {
-	if err := tui.ProgramRun(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
+
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runTUI(args)
+	}
+
+	switch args[0] {
+	case "sandbox":
+		return runSandbox(args[1:])
+	case "test":
+		return runTest(args[1:])
+	case "export":
+		return runExport(args[1:])
+	case "pack":
+		return runPack(args[1:])
+	case "batch":
+		return runBatch(args[1:])
+	case "trace":
+		return runTrace(args[1:])
+	case "profile":
+		return runProfile(args[1:])
+	case "lint":
+		return runLint(args[1:])
+	case "minimize":
+		return runMinimize(args[1:])
+	case "fmt":
+		return runFmt(args[1:])
+	case "lsp":
+		return runLSP(args[1:])
+	case "compile":
+		return runCompile(args[1:])
+	default:
+		return fmt.Errorf("unknown command %s", args[0])
+	}
+}
+
+func runTUI(args []string) error {
+	fs := flag.NewFlagSet("tis-100", flag.ContinueOnError)
+	record := fs.String("record", "", "record runs of programs to an asciicast file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 [-record run.cast] | sandbox | test | export | pack | batch | trace | profile | lint | minimize | fmt | lsp | compile")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return tui.ProgramRun(tui.Options{Record: *record})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/minimizer"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+func runMinimize(args []string) error {
+	fs := flag.NewFlagSet("minimize", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles per test")
+	seeds := fs.Int("seeds", 5, "number of seeds every variant must pass, starting from 1")
+	workers := fs.Int("workers", 0, "number of seeds run at once, 0 uses every CPU")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	output := fs.String("o", "", "file to write the smallest solution to instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 minimize [-cycles n] [-seeds n] [-workers n] [-trusted] [-o out.tis] puzzle.lua|puzzle.json|puzzle.yaml solution.tis")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 || *seeds < 1 {
+		fs.Usage()
+		return errors.New("minimize needs a puzzle, a solution file and at least one seed")
+	}
+
+	puzzles := make([]*types.Puzzle, 0, *seeds)
+	for seed := int64(1); seed <= int64(*seeds); seed++ {
+		puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: seed})
+		if err != nil {
+			return fmt.Errorf("seed %d: %w", seed, err)
+		}
+		defer puzzle.Close()
+		puzzles = append(puzzles, puzzle)
+	}
+	code, err := parser.FetchCode(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := minimizer.Minimize(ctx, puzzles, *code, *workers, *maxCycles)
+	if err != nil && (!errors.Is(err, context.Canceled) || res.Runs == 0) {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "interrupted, keeping the smallest solution found so far")
+	}
+
+	if err := writeOutput(*output, func(w io.Writer) error { return parser.WriteCode(w, &res.Code) }); err != nil {
+		return err
+	}
+	fmt.Fprintf(
+		os.Stderr, "%d INSTR / %d NODES -> %d INSTR / %d NODES after %d variants\n",
+		res.Before.Instructions, res.Before.Nodes, res.Score.Instructions, res.Score.Nodes, res.Runs,
+	)
+	return nil
+}
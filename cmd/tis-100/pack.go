@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+)
+
+func runPack(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles per test")
+	trusted := fs.Bool("trusted", false, "run the puzzle scripts with every Lua library available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 pack [-cycles n] [-trusted] pack.zip|pack-dir")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("pack needs a pack file or directory")
+	}
+
+	pack, err := parser.LoadPack(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted})
+	if err != nil {
+		return err
+	}
+	for _, segment := range pack.Segments {
+		for _, entry := range segment.Puzzles {
+			if entry.Puzzle != nil {
+				defer entry.Puzzle.Close()
+			}
+		}
+	}
+	fmt.Printf("%s %s\n", pack.Name, pack.Version)
+	for _, segment := range pack.Segments {
+		fmt.Printf("%s: %d PUZZLES\n", strings.ToUpper(segment.Name), len(segment.Puzzles))
+	}
+
+	errs := []error{parser.PackErrors(pack), runner.CheckSolutions(pack, *maxCycles)}
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("pack %s is invalid:\n%w", fs.Arg(0), err)
+	}
+	fmt.Println("PACK IS VALID")
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/profile"
+)
+
+func runProfile(args []string) error {
+	fs := flag.NewFlagSet("profile", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles to run")
+	seed := fs.Int64("seed", 0, "seed of math.random, 0 picks a random one")
+	test := fs.String("test", "", "profile the test with this name instead of the puzzle streams")
+	loops := fs.Int("loops", 5, "number of hottest loops to show")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 profile [-cycles n] [-seed n] [-test name] [-loops n] [-trusted] puzzle.lua|puzzle.json|puzzle.yaml solution.tis")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("profile needs a puzzle and a solution file")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+	code, err := parser.FetchCode(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	streams, err := testStreams(puzzle, *test)
+	if err != nil {
+		return err
+	}
+
+	prof, err := profile.Run(puzzle, streams, *code, *maxCycles)
+	if err != nil {
+		return err
+	}
+	return profile.Write(os.Stdout, prof, *code, *loops)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/tui"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+func runSandbox(args []string) error {
+	fs := flag.NewFlagSet("sandbox", flag.ContinueOnError)
+	headless := fs.Bool("headless", false, "read input values from stdin instead of opening the TUI")
+	idle := fs.Int("idle", runner.DefaultIdleCycles, "cycles without output before reading the next value")
+	record := fs.String("record", "", "record runs of programs to an asciicast file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 sandbox [-headless] [-idle cycles] [-record run.cast] [solution.tis]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("sandbox takes at most one solution file")
+	}
+	if *headless && fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("headless sandbox needs a solution file")
+	}
+
+	var code *types.ProgramCode
+	if fs.NArg() == 1 {
+		var err error
+		if code, err = parser.FetchCode(fs.Arg(0)); err != nil {
+			return err
+		}
+	}
+	if !*headless {
+		return tui.ProgramRun(tui.Options{Sandbox: true, Solution: code, Record: *record})
+	}
+	return runner.RunSandbox(*code, os.Stdin, os.Stdout, *idle)
+}
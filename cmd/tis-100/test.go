@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+func runTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles per test")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 test [-cycles n] [-trusted] puzzle.lua|puzzle.json|puzzle.yaml solution.tis")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("test needs a puzzle and a solution file")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+	code, err := parser.FetchCode(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	results, err := runner.RunTests(puzzle, *code, *maxCycles)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, res := range results {
+		if res.Passed {
+			fmt.Fprintf(w, "%s\tPASSED\t%d CYCLES\n", res.Name, res.Score.Cycles)
+		} else {
+			fmt.Fprintf(w, "%s\tFAILED\t%s\n", res.Name, res.Reason)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	passed, score := runner.Summarize(results)
+	if !passed {
+		return errors.New("some tests failed")
+	}
+	fmt.Printf("%d CYCLES / %d NODES / %d INSTR\n", score.Cycles, score.Nodes, score.Instructions)
+	return nil
+}
+
+// testStreams returns the streams of the test with the given name, or the
+// streams of the puzzle if the name is empty.
+func testStreams(puzzle *types.Puzzle, name string) ([]types.Stream, error) {
+	if name == "" {
+		return puzzle.Streams, nil
+	}
+	for _, test := range runner.Tests(puzzle) {
+		if strings.EqualFold(test.Name, name) {
+			return test.Streams, nil
+		}
+	}
+	return nil, fmt.Errorf("puzzle has no test %s", name)
+}
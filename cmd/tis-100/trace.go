@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/trace"
+	"github.com/FranChesK0/tis-100/internal/tui"
+)
+
+func runTrace(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: tis-100 trace record|dump|vcd|view ...")
+	}
+
+	switch args[0] {
+	case "record":
+		return runTraceRecord(args[1:])
+	case "dump":
+		return runTraceDump(args[1:])
+	case "vcd":
+		return runTraceVCD(args[1:])
+	case "view":
+		return runTraceView(args[1:])
+	default:
+		return fmt.Errorf("unknown trace command %s", args[0])
+	}
+}
+
+func runTraceRecord(args []string) error {
+	fs := flag.NewFlagSet("trace record", flag.ContinueOnError)
+	maxCycles := fs.Int("cycles", constants.MaxCycles, "maximum number of cycles to record")
+	seed := fs.Int64("seed", 0, "seed of math.random, 0 picks a random one")
+	test := fs.String("test", "", "record the test with this name instead of the puzzle streams")
+	trusted := fs.Bool("trusted", false, "run the puzzle script with every Lua library available")
+	output := fs.String("o", "", "trace file, the solution file with .trace extension by default")
+	vcd := fs.String("vcd", "", "also write the run as waveforms to this VCD file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 trace record [-cycles n] [-seed n] [-test name] [-trusted] [-o run.trace] [-vcd run.vcd] puzzle.lua|puzzle.json|puzzle.yaml solution.tis")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("trace record needs a puzzle and a solution file")
+	}
+
+	puzzle, err := parser.LoadPuzzle(fs.Arg(0), parser.PuzzleOptions{Trusted: *trusted, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	defer puzzle.Close()
+	code, err := parser.FetchCode(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	streams, err := testStreams(puzzle, *test)
+	if err != nil {
+		return err
+	}
+
+	t, err := trace.Record(puzzle, streams, *code, *maxCycles)
+	if err != nil {
+		return err
+	}
+	file := *output
+	if file == "" {
+		file = strings.TrimSuffix(fs.Arg(1), ".tis") + ".trace"
+	}
+	if err := trace.Save(file, t); err != nil {
+		return err
+	}
+	fmt.Printf("%d CYCLES: %s\n", len(t.Cycles)-1, strings.ToUpper(t.Result))
+	fmt.Printf("trace saved to %s\n", file)
+
+	if *vcd != "" {
+		if err := writeOutput(*vcd, func(w io.Writer) error { return trace.WriteVCD(w, t) }); err != nil {
+			return err
+		}
+		fmt.Printf("waveforms saved to %s\n", *vcd)
+	}
+	return nil
+}
+
+func runTraceDump(args []string) error {
+	fs := flag.NewFlagSet("trace dump", flag.ContinueOnError)
+	from := fs.Int("from", 0, "first cycle to dump")
+	to := fs.Int("to", math.MaxInt, "last cycle to dump")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 trace dump [-from n] [-to n] run.trace")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("trace dump needs a trace file")
+	}
+
+	t, err := trace.Fetch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return trace.Dump(os.Stdout, t, *from, *to)
+}
+
+func runTraceVCD(args []string) error {
+	fs := flag.NewFlagSet("trace vcd", flag.ContinueOnError)
+	output := fs.String("o", "", "write the waveforms to a file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: tis-100 trace vcd [-o run.vcd] run.trace")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("trace vcd needs a trace file")
+	}
+
+	t, err := trace.Fetch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, func(w io.Writer) error {
+		return trace.WriteVCD(w, t)
+	})
+}
+
+func runTraceView(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: tis-100 trace view run.trace")
+	}
+	t, err := trace.Fetch(args[0])
+	if err != nil {
+		return err
+	}
+	return tui.ProgramRun(tui.Options{Trace: t})
+}
@@ -0,0 +1,309 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+var portNames = [...]string{"UP", "RIGHT", "DOWN", "LEFT"}
+
+type analyzer struct {
+	puzzle  *types.Puzzle
+	program *emu.Program
+	// broken nodes have code that does not assemble, so they are assumed to
+	// read from and write to every neighbour.
+	broken map[*emu.Node]bool
+	lines  [][]string
+	diags  []*emu.AssemblyError
+}
+
+// Analyze looks for code of the solution that assembles but is likely wrong
+// on the grid of the puzzle and returns it as warnings sorted by node and
+// line. Nodes with assembly errors are not analyzed.
+func Analyze(puzzle *types.Puzzle, code types.ProgramCode) ([]*emu.AssemblyError, error) {
+	p, err := runner.NewProgram(puzzle)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.LoadStreams(puzzle.Streams); err != nil {
+		return nil, err
+	}
+	if len(code.NodesCode) > len(p.Nodes) {
+		return nil, fmt.Errorf("code has %d nodes, the grid has %d", len(code.NodesCode), len(p.Nodes))
+	}
+
+	a := &analyzer{
+		puzzle:  puzzle,
+		program: p,
+		broken:  make(map[*emu.Node]bool),
+		lines:   make([][]string, len(p.Nodes)),
+	}
+	for i, nc := range code.NodesCode {
+		ic := emu.NewInputCode()
+		for _, line := range nc {
+			ic.AddLine(strings.ToUpper(strings.TrimSpace(line)))
+		}
+		a.lines[i] = ic.Lines
+		if err := p.Nodes[i].ParseCode(&ic); err != nil {
+			a.broken[p.Nodes[i]] = true
+		}
+	}
+
+	for i, n := range p.Nodes {
+		if a.broken[n] || len(n.Instructions) == 0 {
+			continue
+		}
+		a.checkReachable(i, n)
+		a.checkPorts(i, n)
+		a.checkLabels(i)
+		a.checkBAK(i, n)
+		a.checkEffect(i, n)
+	}
+
+	sort.SliceStable(a.diags, func(i, j int) bool {
+		if a.diags[i].Node != a.diags[j].Node {
+			return a.diags[i].Node < a.diags[j].Node
+		}
+		return a.diags[i].Line < a.diags[j].Line
+	})
+	return a.diags, nil
+}
+
+func (a *analyzer) warn(node int, line int, format string, args ...any) {
+	a.diags = append(a.diags, &emu.AssemblyError{
+		Node:     uint8(node),
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+		Severity: emu.WARNING,
+	})
+}
+
+// checkReachable follows every path from the first instruction and warns
+// about instructions no path gets to.
+func (a *analyzer) checkReachable(i int, n *emu.Node) {
+	reached := make([]bool, len(n.Instructions))
+	queue := []int{0}
+	reached[0] = true
+	for len(queue) > 0 {
+		pos := queue[0]
+		queue = queue[1:]
+		for _, next := range successors(n.Instructions, pos) {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for pos, ok := range reached {
+		if !ok {
+			a.warn(i, n.LineNumbers[pos], "unreachable instruction")
+		}
+	}
+}
+
+// successors returns the positions the cursor can move to from pos, with the
+// same wrapping as the emulator.
+func successors(instructions []*emu.Instruction, pos int) []int {
+	target := func(to int) int {
+		if to < 0 || to >= len(instructions) {
+			return 0
+		}
+		return to
+	}
+
+	ins := instructions[pos]
+	next := target(pos + 1)
+	switch ins.Operation {
+	case emu.JMP:
+		return []int{target(int(ins.Src.Number))}
+	case emu.JEZ, emu.JNZ, emu.JGZ, emu.JLZ:
+		return []int{target(int(ins.Src.Number)), next}
+	case emu.JRO:
+		if ins.SrcType == emu.NUMBER {
+			return []int{target(pos + int(ins.Src.Number))}
+		}
+		all := make([]int, len(instructions))
+		for j := range all {
+			all[j] = j
+		}
+		return all
+	default:
+		return []int{next}
+	}
+}
+
+// checkPorts warns about reads from ports nothing writes to and writes to
+// ports nothing reads from, as the node blocks on them forever.
+func (a *analyzer) checkPorts(i int, n *emu.Node) {
+	for pos, ins := range n.Instructions {
+		line := n.LineNumbers[pos]
+		if reads(ins) {
+			if dir := ins.Src.Direction; dir == emu.ANY {
+				if !a.anyWritesTo(n) {
+					a.warn(i, line, "no neighbour ever writes to ANY")
+				}
+			} else if dir <= emu.LEFT {
+				if msg := a.checkPort(n, dir, emu.WRITEONLY); msg != "" {
+					a.warn(i, line, "read from %s, which %s", portNames[dir], msg)
+				} else if !a.writesTo(n.Ports[dir], n) {
+					a.warn(i, line, "no neighbour ever writes to %s", portNames[dir])
+				}
+			}
+		}
+		if writes(ins) {
+			if dir := ins.Dest.Direction; dir <= emu.LEFT {
+				if msg := a.checkPort(n, dir, emu.READONLY); msg != "" {
+					a.warn(i, line, "write to %s, which %s", portNames[dir], msg)
+				} else if !a.readsFrom(n.Ports[dir], n) {
+					a.warn(i, line, "no neighbour ever reads from %s", portNames[dir])
+				}
+			}
+		}
+	}
+}
+
+// checkPort tells why the port can not be used when it has the denied
+// access, or returns an empty string.
+func (a *analyzer) checkPort(n *emu.Node, dir emu.LocationDirection, denied emu.PortAccess) string {
+	port := n.Ports[dir]
+	switch {
+	case port == nil && a.puzzle.Connections == nil:
+		return "faces the grid edge"
+	case port == nil:
+		return "is not connected"
+	case n.Access[dir] == denied && denied == emu.READONLY:
+		return "is read only"
+	case n.Access[dir] == denied:
+		return "is write only"
+	case a.damaged(port):
+		return "faces a damaged node"
+	}
+	return ""
+}
+
+func (a *analyzer) damaged(n *emu.Node) bool {
+	i := int(n.Index)
+	return i < len(a.program.Nodes) && a.program.Nodes[i] == n &&
+		i < len(a.puzzle.Layout) && a.puzzle.Layout[i] == constants.DAMAGED
+}
+
+func (a *analyzer) anyWritesTo(n *emu.Node) bool {
+	for dir, port := range n.Ports {
+		if port != nil && n.Access[dir] != emu.WRITEONLY && a.writesTo(port, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// writesTo reports whether any instruction of from may write to to.
+func (a *analyzer) writesTo(from *emu.Node, to *emu.Node) bool {
+	if a.broken[from] {
+		return true
+	}
+	for _, ins := range from.Instructions {
+		if writes(ins) && usesPort(from, ins.Dest.Direction, to, emu.READONLY) {
+			return true
+		}
+	}
+	return false
+}
+
+// readsFrom reports whether any instruction of to may read from from.
+func (a *analyzer) readsFrom(to *emu.Node, from *emu.Node) bool {
+	if a.broken[to] {
+		return true
+	}
+	for _, ins := range to.Instructions {
+		if reads(ins) && usesPort(to, ins.Src.Direction, from, emu.WRITEONLY) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesPort reports whether n may reach neighbour through dir, unless the port
+// has the denied access.
+func usesPort(n *emu.Node, dir emu.LocationDirection, neighbour *emu.Node, denied emu.PortAccess) bool {
+	for d, port := range n.Ports {
+		if port != neighbour || n.Access[d] == denied {
+			continue
+		}
+		if dir == emu.ANY || dir == emu.LAST || dir == emu.LocationDirection(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func reads(ins *emu.Instruction) bool {
+	switch ins.Operation {
+	case emu.MOV, emu.ADD, emu.SUB, emu.JRO:
+		return ins.SrcType == emu.ADDRESS && isPort(ins.Src.Direction)
+	}
+	return false
+}
+
+func writes(ins *emu.Instruction) bool {
+	return ins.Operation == emu.MOV && ins.DestType == emu.ADDRESS && isPort(ins.Dest.Direction)
+}
+
+func isPort(dir emu.LocationDirection) bool {
+	return dir <= emu.LEFT || dir == emu.ANY || dir == emu.LAST
+}
+
+// checkLabels warns about labels no jump of the node refers to.
+func (a *analyzer) checkLabels(i int) {
+	defined := make(map[string]int)
+	used := make(map[string]bool)
+	for line, text := range a.lines[i] {
+		for j, tok := range emu.Tokenize(text) {
+			if tok.Type != emu.LABEL || tok.Value == "" {
+				continue
+			}
+			if j > 0 {
+				used[tok.Value] = true
+			} else if _, ok := defined[tok.Value]; !ok {
+				defined[tok.Value] = line
+			}
+		}
+	}
+
+	for label, line := range defined {
+		if !used[label] {
+			a.warn(i, line, "label %s is never jumped to", label)
+		}
+	}
+}
+
+// checkBAK warns about values saved to BAK that are never swapped back.
+func (a *analyzer) checkBAK(i int, n *emu.Node) {
+	for _, ins := range n.Instructions {
+		if ins.Operation == emu.SWP {
+			return
+		}
+	}
+	for pos, ins := range n.Instructions {
+		if ins.Operation == emu.SAV {
+			a.warn(i, n.LineNumbers[pos], "BAK is saved but never swapped back")
+		}
+	}
+}
+
+// checkEffect warns about nodes that never use a port. They never block, but
+// nothing they compute ever leaves them either.
+func (a *analyzer) checkEffect(i int, n *emu.Node) {
+	for _, ins := range n.Instructions {
+		if reads(ins) || writes(ins) {
+			return
+		}
+	}
+	a.warn(i, n.LineNumbers[0], "node never reads or writes a port, its code has no effect")
+}
@@ -0,0 +1,109 @@
+package analyzer_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/analyzer"
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Analyze
+func TestAnalyze(t *testing.T) {
+	code := types.ProgramCode{NodesCode: [][]string{
+		{"S: MOV UP, ACC", "SAV", "MOV ACC, RIGHT", "MOV ACC, DOWN", "MOV LEFT, ACC", "JMP S", "ADD 1", "X: NOP"},
+		{},
+		{"MOV UP, DOWN", "MOV RIGHT, ACC"},
+		{"ADD 1", "NEG"},
+	}}
+	diags, err := analyzer.Analyze(newPuzzle(), code)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"node 1, line 2: warning: BAK is saved but never swapped back",
+		"node 1, line 3: warning: write to RIGHT, which faces a damaged node",
+		"node 1, line 5: warning: read from LEFT, which faces the grid edge",
+		"node 1, line 7: warning: unreachable instruction",
+		"node 1, line 8: warning: unreachable instruction",
+		"node 1, line 8: warning: label X is never jumped to",
+		"node 3, line 2: warning: no neighbour ever writes to RIGHT",
+		"node 4, line 1: warning: node never reads or writes a port, its code has no effect",
+	}
+	if got := messages(diags); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong diagnostics. expected:\n%v\ngot:\n%v", expected, got)
+	}
+	for _, diag := range diags {
+		if diag.Severity != emu.WARNING {
+			t.Errorf("wrong severity. expected: %s, got: %s", emu.WARNING, diag.Severity)
+		}
+	}
+}
+
+func TestAnalyzeWithBrokenNode(t *testing.T) {
+	code := types.ProgramCode{NodesCode: [][]string{
+		{"MOV UP, DOWN"},
+		{},
+		{"MOV UP, DOWN", "MOV RIGHT, ACC", "MOV ACC, RIGHT"},
+		{"MOV X, LEFT"},
+	}}
+	diags, err := analyzer.Analyze(newPuzzle(), code)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got: %v", messages(diags))
+	}
+}
+
+func TestAnalyzeWithOneWayConnection(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Layout[1] = constants.COMPUTE
+	puzzle.Connections = []types.Connection{
+		{From: 0, FromPort: constants.RIGHT, To: 1, ToPort: constants.LEFT, OneWay: true},
+	}
+	code := types.ProgramCode{NodesCode: [][]string{
+		{"MOV RIGHT, ACC", "MOV ACC, RIGHT"},
+		{"MOV LEFT, ACC", "MOV ACC, LEFT", "MOV ACC, DOWN"},
+	}}
+	diags, err := analyzer.Analyze(puzzle, code)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"node 1, line 1: warning: read from RIGHT, which is write only",
+		"node 2, line 2: warning: write to LEFT, which is read only",
+		"node 2, line 3: warning: write to DOWN, which is not connected",
+	}
+	if got := messages(diags); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong diagnostics. expected:\n%v\ngot:\n%v", expected, got)
+	}
+}
+
+/* UTILS */
+func newPuzzle() *types.Puzzle {
+	return &types.Puzzle{
+		Title:  "TEST",
+		Width:  2,
+		Height: 2,
+		Layout: []types.NodeType{constants.COMPUTE, constants.DAMAGED, constants.COMPUTE, constants.COMPUTE},
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{1}},
+			{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{1}},
+		},
+	}
+}
+
+func messages(diags []*emu.AssemblyError) []string {
+	msgs := make([]string, 0, len(diags))
+	for _, diag := range diags {
+		msgs = append(msgs, diag.Error())
+	}
+	return msgs
+}
@@ -0,0 +1,627 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+type opKind uint8
+
+const (
+	opInput opKind = iota
+	opOutput
+	opAdd
+	opSub
+	opNeg
+	opMul
+	opCmp
+	opAcc
+)
+
+// operand is the value produced by an op or a literal when op is nil.
+type operand struct {
+	op    *op
+	value int
+}
+
+// op is an operation placed on a node of its own. It reads its operands
+// once per value of the input streams and writes its result to every user.
+// An op reading from input reads it from UP and one writing to output writes
+// to DOWN. Inputs and outputs fused into the op next to them have no node.
+type op struct {
+	kind   opKind
+	line   int
+	index  int
+	input  *types.Stream
+	output *types.Stream
+	fused  bool
+	cmp    string
+	factor int
+	args   []operand
+	users  []*op
+	tile   int
+}
+
+type compiler struct {
+	puzzle  *types.Puzzle
+	ops     []*op
+	inputs  map[string]*op
+	outputs map[string]*op
+	vars    map[string]operand
+	line    int
+}
+
+// Compile translates a program of the expression language into code for the
+// grid of puzzle.
+//
+// Every line of a program assigns an expression to a name, several
+// assignments on a line are separated by ";" and "#" starts a comment.
+// Names of the input streams of the puzzle read the next value of the
+// stream, assigning to the name of an output stream writes to it and other
+// names are variables, which are assigned once before they are used. The
+// whole program runs once for every value of the input streams.
+//
+// "REPEAT n: OUT = x" loops in the node of the output stream, writing x to it
+// n times for every value, or not at all when n is not positive. A count that
+// is a number must be positive.
+//
+// Expressions are made of numbers, names, parentheses, "+", "-", "*" and the
+// comparisons "==", "!=", "<", ">", "<=" and ">=", which are 1 when they hold
+// and 0 otherwise. One side of "*" must be a number, as nodes only multiply by
+// adding. acc(x) is the sum of every value of x so far and acc(x, r) starts
+// over after a value of r that is not 0.
+//
+// Every operation gets a node of its own and values are moved between them
+// by nodes that pass them on, so the grid must have room for both. Damaged
+// nodes are left alone, puzzles with custom connections are not supported.
+func Compile(puzzle *types.Puzzle, src string) (*types.ProgramCode, error) {
+	if puzzle.Connections != nil {
+		return nil, errors.New("puzzles with connections are not supported")
+	}
+	statements, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiler{
+		puzzle:  puzzle,
+		inputs:  make(map[string]*op),
+		outputs: make(map[string]*op),
+		vars:    make(map[string]operand),
+	}
+	for _, st := range statements {
+		c.line = st.line
+		if err := c.assign(st); err != nil {
+			return nil, fmt.Errorf("line %d: %w", st.line, err)
+		}
+	}
+	if len(c.outputs) == 0 {
+		return nil, errors.New("program writes to no output stream")
+	}
+
+	ops := fuse(live(c.ops))
+	for _, o := range ops {
+		if len(neighbours(o)) > constants.PortsNumber {
+			return nil, fmt.Errorf("line %d: value is used by too many expressions", o.line)
+		}
+	}
+
+	l := newLayout(puzzle)
+	if err := l.place(ops); err != nil {
+		return nil, err
+	}
+	for _, o := range ops {
+		lines := l.code(o)
+		if len(lines) > constants.MaxNodeLines {
+			return nil, fmt.Errorf(
+				"line %d: expression needs %d instructions, at most %d fit in a node",
+				o.line, len(lines), constants.MaxNodeLines,
+			)
+		}
+		l.nodes[o.tile] = lines
+	}
+	return &types.ProgramCode{Title: puzzle.Title, NodesCode: l.nodes}, nil
+}
+
+func (c *compiler) assign(st statement) error {
+	if _, ok := c.vars[st.target]; ok {
+		return fmt.Errorf("%s is assigned twice", st.target)
+	}
+	stream, ok := c.stream(st.target)
+	if ok {
+		if stream.Type == constants.INPUT {
+			return fmt.Errorf("can not assign to input stream %s", st.target)
+		}
+		if _, ok := c.outputs[st.target]; ok {
+			return fmt.Errorf("%s is assigned twice", st.target)
+		}
+	} else if st.count != nil {
+		return fmt.Errorf("REPEAT can only write to an output stream, not %s", st.target)
+	}
+
+	// a count of 1 is an ordinary assignment
+	count := operand{value: 1}
+	if st.count != nil {
+		var err error
+		if count, err = c.value(st.count); err != nil {
+			return err
+		}
+		if count.op == nil && count.value <= 0 {
+			return fmt.Errorf("%s is repeated %d times", st.target, count.value)
+		}
+	}
+	value, err := c.value(st.value)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		c.vars[st.target] = value
+		return nil
+	}
+	if value.op == nil && count.op == nil {
+		return fmt.Errorf("%s does not depend on an input stream", st.target)
+	}
+	args := []operand{value}
+	if count.op != nil || count.value != 1 {
+		args = append(args, count)
+	}
+	o := c.newOp(opOutput, args...)
+	o.output = &stream
+	c.outputs[st.target] = o
+	return nil
+}
+
+func (c *compiler) stream(name string) (types.Stream, bool) {
+	for _, stream := range c.puzzle.Streams {
+		if stream.Name == name {
+			return stream, true
+		}
+	}
+	return types.Stream{}, false
+}
+
+func (c *compiler) newOp(kind opKind, args ...operand) *op {
+	o := &op{kind: kind, line: c.line, args: args}
+	c.ops = append(c.ops, o)
+	return o
+}
+
+func (c *compiler) value(e expr) (operand, error) {
+	switch e := e.(type) {
+	case number:
+		if e.value > constants.MaxACC {
+			return operand{}, fmt.Errorf("%d is out of range", e.value)
+		}
+		return operand{value: e.value}, nil
+	case name:
+		if value, ok := c.vars[e.name]; ok {
+			return value, nil
+		}
+		if o, ok := c.inputs[e.name]; ok {
+			return operand{op: o}, nil
+		}
+		stream, ok := c.stream(e.name)
+		if !ok {
+			return operand{}, fmt.Errorf("undefined name %s", e.name)
+		}
+		if stream.Type != constants.INPUT {
+			return operand{}, fmt.Errorf("can not read from output stream %s", e.name)
+		}
+		o := c.newOp(opInput)
+		o.input = &stream
+		c.inputs[e.name] = o
+		return operand{op: o}, nil
+	case unary:
+		x, err := c.value(e.x)
+		if err != nil {
+			return operand{}, err
+		}
+		if x.op == nil {
+			return operand{value: -x.value}, nil
+		}
+		return operand{op: c.newOp(opNeg, x)}, nil
+	case binary:
+		x, err := c.value(e.x)
+		if err != nil {
+			return operand{}, err
+		}
+		y, err := c.value(e.y)
+		if err != nil {
+			return operand{}, err
+		}
+		return c.binary(e.op, x, y)
+	case call:
+		return c.call(e)
+	}
+	return operand{}, fmt.Errorf("unknown expression %v", e)
+}
+
+func (c *compiler) binary(operator string, x operand, y operand) (operand, error) {
+	if x.op == nil && y.op == nil {
+		return operand{value: fold(operator, x.value, y.value)}, nil
+	}
+	switch operator {
+	case "+":
+		if x.op == nil && x.value == 0 {
+			return y, nil
+		}
+		if y.op == nil && y.value == 0 {
+			return x, nil
+		}
+		return operand{op: c.newOp(opAdd, x, y)}, nil
+	case "-":
+		if y.op == nil && y.value == 0 {
+			return x, nil
+		}
+		return operand{op: c.newOp(opSub, x, y)}, nil
+	case "*":
+		if x.op == nil {
+			x, y = y, x
+		}
+		if y.op != nil {
+			return operand{}, errors.New("one side of * must be a number")
+		}
+		switch y.value {
+		case 0:
+			return operand{}, nil
+		case 1:
+			return x, nil
+		case -1:
+			return operand{op: c.newOp(opNeg, x)}, nil
+		}
+		o := c.newOp(opMul, x)
+		o.factor = y.value
+		return operand{op: o}, nil
+	}
+	o := c.newOp(opCmp, x, y)
+	o.cmp = operator
+	return operand{op: o}, nil
+}
+
+func (c *compiler) call(e call) (operand, error) {
+	if e.fn != "ACC" {
+		return operand{}, fmt.Errorf("unknown function %s", e.fn)
+	}
+	if len(e.args) != 1 && len(e.args) != 2 {
+		return operand{}, fmt.Errorf("acc takes 1 or 2 arguments, got %d", len(e.args))
+	}
+	args := make([]operand, 0, len(e.args))
+	for _, arg := range e.args {
+		value, err := c.value(arg)
+		if err != nil {
+			return operand{}, err
+		}
+		args = append(args, value)
+	}
+	if args[0].op == nil {
+		return operand{}, errors.New("acc needs a value that depends on an input stream")
+	}
+	if len(args) == 2 && args[1].op == nil {
+		if args[1].value != 0 {
+			return args[0], nil
+		}
+		args = args[:1]
+	}
+	return operand{op: c.newOp(opAcc, args...)}, nil
+}
+
+// fold computes the operator on numbers the way the nodes would.
+func fold(operator string, x int, y int) int {
+	result := 0
+	switch operator {
+	case "+":
+		result = x + y
+	case "-":
+		result = x - y
+	case "*":
+		result = x * y
+	default:
+		if compare(operator, x, y) {
+			result = 1
+		}
+	}
+	return min(max(result, constants.MinACC), constants.MaxACC)
+}
+
+func compare(operator string, x int, y int) bool {
+	switch operator {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	case "<":
+		return x < y
+	case ">":
+		return x > y
+	case "<=":
+		return x <= y
+	}
+	return x >= y
+}
+
+// live returns the ops the outputs depend on in the order they were made,
+// which puts every op after its operands, and fills in their users.
+func live(ops []*op) []*op {
+	used := make(map[*op]bool)
+	for i := len(ops) - 1; i >= 0; i-- {
+		if ops[i].kind == opOutput || used[ops[i]] {
+			used[ops[i]] = true
+			for _, p := range producers(ops[i]) {
+				used[p] = true
+			}
+		}
+	}
+
+	result := make([]*op, 0, len(used))
+	for _, o := range ops {
+		if used[o] {
+			o.index = len(result)
+			result = append(result, o)
+			for _, p := range producers(o) {
+				p.users = append(p.users, o)
+			}
+		}
+	}
+	return result
+}
+
+// fuse moves inputs read by a single op into that op and ops written only
+// to an output into the output, which saves a node each. An op can be fused
+// with a single stream, and an input only if it is read first.
+func fuse(ops []*op) []*op {
+	for _, o := range ops {
+		p := first(o)
+		if p != nil && p.kind == opInput && len(p.users) == 1 && reads(o, p) == 1 && o.kind != opOutput {
+			p.fused = true
+			o.input = p.input
+		}
+	}
+	for _, o := range ops {
+		if o.kind != opOutput || len(o.args) > 1 {
+			continue
+		}
+		p := o.args[0].op
+		if p.input == nil && len(p.users) == 1 && p.kind != opOutput {
+			o.fused = true
+			p.output = o.output
+		}
+	}
+
+	result := make([]*op, 0, len(ops))
+	for _, o := range ops {
+		if !o.fused {
+			result = append(result, o)
+		}
+	}
+	return result
+}
+
+// first returns the op o reads from first.
+func first(o *op) *op {
+	var result *op
+	for _, p := range producers(o) {
+		if result == nil || p.index < result.index {
+			result = p
+		}
+	}
+	return result
+}
+
+// neighbours returns the ops the node of o is connected to, streams count
+// as nil.
+func neighbours(o *op) []*op {
+	result := make([]*op, 0)
+	if o.input != nil {
+		result = append(result, nil)
+	}
+	if o.output != nil {
+		result = append(result, nil)
+	}
+	for _, p := range producers(o) {
+		if !p.fused {
+			result = append(result, p)
+		}
+	}
+	for _, user := range o.users {
+		if !user.fused {
+			result = append(result, user)
+		}
+	}
+	return result
+}
+
+// producers returns the distinct ops o reads from.
+func producers(o *op) []*op {
+	result := make([]*op, 0, len(o.args))
+	for _, arg := range o.args {
+		if arg.op != nil && !containsOp(result, arg.op) {
+			result = append(result, arg.op)
+		}
+	}
+	return result
+}
+
+func containsOp(ops []*op, o *op) bool {
+	for _, item := range ops {
+		if item == o {
+			return true
+		}
+	}
+	return false
+}
+
+// reads returns how many values o reads from the producer p each time.
+func reads(o *op, p *op) int {
+	if o.kind == opMul {
+		return bits.OnesCount(uint(abs(o.factor)))
+	}
+	count := 0
+	for _, arg := range o.args {
+		if arg.op == p {
+			count++
+		}
+	}
+	return count
+}
+
+// earlier orders operands by the time they are read. Operands are read in
+// the order their ops were made and users are written to in the same order,
+// so no two nodes ever wait on each other.
+func earlier(a operand, b operand) bool {
+	return a.op != nil && (b.op == nil || a.op.index < b.op.index)
+}
+
+var flipped = map[string]string{"==": "==", "!=": "!=", "<": ">", ">": "<", "<=": ">=", ">=": "<="}
+
+var jumps = map[string]struct {
+	jump      string
+	ifJump    int
+	otherwise int
+}{
+	"==": {"JEZ", 1, 0},
+	"!=": {"JNZ", 1, 0},
+	">":  {"JGZ", 1, 0},
+	"<":  {"JLZ", 1, 0},
+	">=": {"JLZ", 0, 1},
+	"<=": {"JGZ", 0, 1},
+}
+
+// body returns the instructions of o that leave its result in ACC. src names
+// where an operand is read from.
+func body(o *op, src func(operand) string) []string {
+	switch o.kind {
+	case opInput:
+		return []string{"MOV UP, ACC"}
+	case opAdd:
+		x, y := o.args[0], o.args[1]
+		if !earlier(x, y) {
+			x, y = y, x
+		}
+		return []string{"MOV " + src(x) + ", ACC", "ADD " + src(y)}
+	case opSub:
+		x, y := o.args[0], o.args[1]
+		switch {
+		case y.op == nil:
+			return []string{"MOV " + src(x) + ", ACC", add(-y.value)}
+		case earlier(y, x) && x.op != nil:
+			return []string{"MOV " + src(y) + ", ACC", "SUB " + src(x), "NEG"}
+		}
+		return []string{"MOV " + src(x) + ", ACC", "SUB " + src(y)}
+	case opNeg:
+		return []string{"MOV " + src(o.args[0]) + ", ACC", "NEG"}
+	case opMul:
+		lines := make([]string, 0)
+		factor := abs(o.factor)
+		for i := bits.Len(uint(factor)) - 1; i >= 0; i-- {
+			if len(lines) == 0 {
+				lines = append(lines, "MOV "+src(o.args[0])+", ACC")
+				continue
+			}
+			lines = append(lines, "ADD ACC")
+			if factor&(1<<i) != 0 {
+				lines = append(lines, "ADD "+src(o.args[0]))
+			}
+		}
+		if o.factor < 0 {
+			lines = append(lines, "NEG")
+		}
+		return lines
+	case opCmp:
+		x, y, cmp := o.args[0], o.args[1], o.cmp
+		if !earlier(x, y) {
+			x, y, cmp = y, x, flipped[cmp]
+		}
+		lines := []string{"MOV " + src(x) + ", ACC"}
+		if y.op != nil {
+			lines = append(lines, "SUB "+src(y))
+		} else if y.value != 0 {
+			lines = append(lines, add(-y.value))
+		}
+		j := jumps[cmp]
+		return append(lines,
+			j.jump+" T",
+			"MOV "+strconv.Itoa(j.otherwise)+", ACC",
+			"JMP W",
+			"T: MOV "+strconv.Itoa(j.ifJump)+", ACC",
+		)
+	case opAcc:
+		return []string{"ADD " + src(o.args[0])}
+	}
+	return nil
+}
+
+// code returns the whole code of the node of o. write is the instructions
+// sending ACC to the users of o.
+func code(o *op, src func(operand) string, write []string) []string {
+	switch o.kind {
+	case opOutput:
+		if len(o.args) == 2 {
+			return repeat(o, src)
+		}
+		return []string{"MOV " + src(o.args[0]) + ", DOWN"}
+	case opAcc:
+		if len(o.args) == 2 {
+			return reset(o, src, write)
+		}
+	case opCmp:
+		lines := body(o, src)
+		write = append([]string{"W: " + write[0]}, write[1:]...)
+		return append(lines, write...)
+	}
+
+	lines := body(o, src)
+	if len(lines) == 1 && len(write) == 1 && o.kind != opAcc {
+		// MOV x, ACC followed by MOV ACC, y
+		return []string{lines[0][:len(lines[0])-len("ACC")] + write[0][len("MOV ACC, "):]}
+	}
+	return append(lines, write...)
+}
+
+// reset returns the code of acc(x, r), which keeps the sum in BAK. Whichever
+// operand comes first is read first.
+func reset(o *op, src func(operand) string, write []string) []string {
+	x, r := src(o.args[0]), src(o.args[1])
+	lines := make([]string, 0)
+	if earlier(o.args[1], o.args[0]) {
+		lines = append(lines, "S: MOV "+r+", ACC", "JNZ R", "SWP", "ADD "+x)
+		lines = append(lines, write...)
+		lines = append(lines, "SAV", "JMP S", "R: SWP", "ADD "+x)
+	} else {
+		lines = append(lines, "S: SWP", "ADD "+x, "SAV", "MOV "+r+", ACC", "JNZ R", "SWP")
+		lines = append(lines, write...)
+		lines = append(lines, "SAV", "JMP S", "R: SWP")
+	}
+	lines = append(lines, write...)
+	return append(lines, "MOV 0, ACC", "SAV")
+}
+
+// repeat returns the code of REPEAT n: OUT = x, which keeps x in BAK and
+// counts n down in ACC, writing x until the count is not positive. Whichever
+// operand comes first is read first.
+func repeat(o *op, src func(operand) string) []string {
+	x, n := o.args[0], o.args[1]
+	lines := []string{"S: MOV " + src(x) + ", ACC", "SAV", "MOV " + src(n) + ", ACC"}
+	if earlier(n, x) {
+		lines = []string{"S: MOV " + src(n) + ", ACC", "SWP", "MOV " + src(x) + ", ACC", "SWP"}
+	}
+	return append(lines, "L: JLZ S", "JEZ S", "SWP", "MOV ACC, DOWN", "SWP", "SUB 1", "JMP L")
+}
+
+func add(value int) string {
+	if value < 0 {
+		return "SUB " + strconv.Itoa(-value)
+	}
+	return "ADD " + strconv.Itoa(value)
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,229 @@
+package compiler_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/compiler"
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Compile
+func TestCompile(t *testing.T) {
+	sum, total := 0, 0
+	tests := []struct {
+		src      string
+		expected func(a int, b int) (int, int)
+	}{
+		{
+			src: "x = in.a * 3 - in.b\nOUT.A = x; OUT.B = -x # negated",
+			expected: func(a int, b int) (int, int) {
+				return 3*a - b, b - 3*a
+			},
+		},
+		{
+			src: "OUT.A = IN.A > IN.B\nOUT.B = (IN.B <= 2) + 10",
+			expected: func(a int, b int) (int, int) {
+				return boolean(a > b), boolean(b <= 2) + 10
+			},
+		},
+		{
+			src: "OUT.A = 5 - IN.A * -2\nOUT.B = IN.B != IN.A",
+			expected: func(a int, b int) (int, int) {
+				return 5 + 2*a, boolean(b != a)
+			},
+		},
+		{
+			src: "OUT.A = acc(IN.A)\nOUT.B = acc(IN.B, IN.A == 0)",
+			expected: func(a int, b int) (int, int) {
+				sum += a
+				total += b
+				result := total
+				if a == 0 {
+					total = 0
+				}
+				return sum, result
+			},
+		},
+		{
+			src: "OUT.A = acc(IN.A, IN.B > 3)\nOUT.B = IN.B",
+			expected: func(a int, b int) (int, int) {
+				sum += a
+				result := sum
+				if b > 3 {
+					sum = 0
+				}
+				return result, b
+			},
+		},
+	}
+	for _, test := range tests {
+		sum, total = 0, 0
+		checkProgram(t, newPuzzle(test.expected), test.src)
+	}
+}
+
+func TestCompileWithDamagedNodes(t *testing.T) {
+	sum := 0
+	puzzle := newPuzzle(func(a int, b int) (int, int) {
+		sum += b
+		return 3*a - b, sum
+	})
+	puzzle.Layout[1] = constants.DAMAGED
+	puzzle.Layout[5] = constants.DAMAGED
+
+	code := checkProgram(t, puzzle, "OUT.A = IN.A * 3 - IN.B\nOUT.B = acc(IN.B)")
+	if len(code.NodesCode[1]) > 0 || len(code.NodesCode[5]) > 0 {
+		t.Errorf("wrong code. expected no code on damaged nodes, got: %q", code.NodesCode)
+	}
+}
+
+func TestCompileWithRepeat(t *testing.T) {
+	tests := []struct {
+		src   string
+		count func(a int, b int) int
+		value func(a int, b int) int
+	}{
+		{
+			src:   "REPEAT IN.B: OUT.A = IN.A * 2",
+			count: func(a int, b int) int { return b },
+			value: func(a int, b int) int { return 2 * a },
+		},
+		{
+			src:   "N = IN.B - 1\nX = IN.A + 1\nREPEAT N: OUT.A = X",
+			count: func(a int, b int) int { return b - 1 },
+			value: func(a int, b int) int { return a + 1 },
+		},
+		{
+			src:   "repeat 3: out.a = in.a",
+			count: func(a int, b int) int { return 3 },
+			value: func(a int, b int) int { return a },
+		},
+		{
+			src:   "REPEAT IN.A > IN.B: OUT.A = 7",
+			count: func(a int, b int) int { return boolean(a > b) },
+			value: func(a int, b int) int { return 7 },
+		},
+		{
+			src:   "REPEAT = IN.A\nREPEAT REPEAT: OUT.A = REPEAT",
+			count: func(a int, b int) int { return a },
+			value: func(a int, b int) int { return a },
+		},
+	}
+	for _, test := range tests {
+		outA, outB := make([]int16, 0), make([]int16, 0)
+		for i := range inputs[0] {
+			a, b := inputs[0][i], inputs[1][i]
+			for range test.count(a, b) {
+				outA = append(outA, clamp(test.value(a, b)))
+			}
+			outB = append(outB, clamp(b))
+		}
+		checkProgram(t, newPuzzleWithOutputs(outA, outB), test.src+"\nOUT.B = IN.B")
+	}
+}
+
+func TestCompileWithWrongProgram(t *testing.T) {
+	tests := []struct {
+		src     string
+		message string
+	}{
+		{"OUT.A = IN.A +", "line 1: expected an expression"},
+		{"OUT.A = IN.A $ 2", "line 1: unexpected character"},
+		{"X = IN.A\n\nOUT.A = Y", "line 3: undefined name Y"},
+		{"X = IN.A; X = IN.B", "line 1: X is assigned twice"},
+		{"IN.A = 3", "line 1: can not assign to input stream IN.A"},
+		{"OUT.A = 2 * 3", "line 1: OUT.A does not depend on an input stream"},
+		{"OUT.A = IN.A * IN.B", "line 1: one side of * must be a number"},
+		{"OUT.A = max(IN.A)", "line 1: unknown function MAX"},
+		{"REPEAT IN.B OUT.A = IN.A", "line 1: expected :, got OUT.A"},
+		{"REPEAT IN.B: X = IN.A", "line 1: REPEAT can only write to an output stream, not X"},
+		{"REPEAT 0: OUT.A = IN.A", "line 1: OUT.A is repeated 0 times"},
+		{"REPEAT 3: OUT.A = 5", "line 1: OUT.A does not depend on an input stream"},
+		{"X = IN.A", "program writes to no output stream"},
+		{"OUT.A = IN.A * 999", "instructions, at most 15 fit in a node"},
+		{"OUT.A = (IN.A + 1) * 3 + (IN.B - 1) * 5 + (IN.A > IN.B)", "program does not fit on the grid"},
+	}
+	puzzle := newPuzzle(nil)
+	for _, test := range tests {
+		_, err := compiler.Compile(puzzle, test.src)
+		if err == nil {
+			t.Errorf("expected to occure error for %q, got nil", test.src)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.message) {
+			t.Errorf("wrong error for %q. expected: %s, got: %s", test.src, test.message, err)
+		}
+	}
+}
+
+/* UTILS */
+var inputs = [][]int{{3, 0, -2, 7, 0, 1, 5, 0}, {1, 4, 2, 2, 9, -3, 0, 4}}
+
+// newPuzzle returns a puzzle on the default grid with inputs IN.A and IN.B
+// and outputs OUT.A and OUT.B expected to be what f returns for the inputs.
+func newPuzzle(f func(a int, b int) (int, int)) *types.Puzzle {
+	outA, outB := make([]int16, 0), make([]int16, 0)
+	for i := range inputs[0] {
+		if f != nil {
+			a, b := f(inputs[0][i], inputs[1][i])
+			outA, outB = append(outA, clamp(a)), append(outB, clamp(b))
+		}
+	}
+	return newPuzzleWithOutputs(outA, outB)
+}
+
+// newPuzzleWithOutputs is newPuzzle with the values of OUT.A and OUT.B given.
+func newPuzzleWithOutputs(outA []int16, outB []int16) *types.Puzzle {
+	return &types.Puzzle{
+		Title:  "TEST",
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
+		Layout: make([]types.NodeType, constants.NodesNumber),
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN.A", Position: 0, Values: values(inputs[0])},
+			{Type: constants.INPUT, Name: "IN.B", Position: 2, Values: values(inputs[1])},
+			{Type: constants.OUTPUT, Name: "OUT.A", Position: 1, Values: outA},
+			{Type: constants.OUTPUT, Name: "OUT.B", Position: 3, Values: outB},
+		},
+	}
+}
+
+func checkProgram(t *testing.T, puzzle *types.Puzzle, src string) *types.ProgramCode {
+	t.Helper()
+	code, err := compiler.Compile(puzzle, src)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	results, err := runner.RunTests(puzzle, *code, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if passed, _ := runner.Summarize(results); !passed {
+		t.Errorf("wrong code for %q: %s\n%q", src, results[0].Reason, code.NodesCode)
+	}
+	return code
+}
+
+func values(ints []int) []int16 {
+	result := make([]int16, 0, len(ints))
+	for _, value := range ints {
+		result = append(result, int16(value))
+	}
+	return result
+}
+
+func clamp(value int) int16 {
+	return int16(min(max(value, constants.MinACC), constants.MaxACC))
+}
+
+func boolean(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
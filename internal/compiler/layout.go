@@ -0,0 +1,345 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// searchBudget is the number of ops placed before the search for a layout
+// in one order of directions gives up.
+const searchBudget = 10000
+
+// portNames are indexed by direction, opposite directions are two apart.
+var portNames = [constants.PortsNumber]string{"UP", "RIGHT", "DOWN", "LEFT"}
+
+const (
+	up = iota
+	right
+	down
+	left
+)
+
+type edge struct {
+	from *op
+	to   *op
+}
+
+// layout is the grid the ops are placed on. Nodes passing values on are
+// added to nodes as edges are routed.
+type layout struct {
+	width   int
+	height  int
+	damaged []bool
+	used    []bool
+	ports   [][constants.PortsNumber]bool
+	nodes   [][]string
+	out     map[edge]int
+	in      map[edge]int
+	dirs    []int
+}
+
+func newLayout(puzzle *types.Puzzle) *layout {
+	size := puzzle.Width * puzzle.Height
+	l := &layout{
+		width:   puzzle.Width,
+		height:  puzzle.Height,
+		damaged: make([]bool, size),
+		used:    make([]bool, size),
+		ports:   make([][constants.PortsNumber]bool, size),
+		nodes:   make([][]string, size),
+		out:     make(map[edge]int),
+		in:      make(map[edge]int),
+	}
+	for i := range size {
+		l.damaged[i] = i < len(puzzle.Layout) && puzzle.Layout[i] == constants.DAMAGED
+	}
+	return l
+}
+
+func (l *layout) clone() *layout {
+	c := *l
+	c.used = append([]bool{}, l.used...)
+	c.ports = append([][constants.PortsNumber]bool{}, l.ports...)
+	c.nodes = append([][]string{}, l.nodes...)
+	c.out = make(map[edge]int, len(l.out))
+	for e, dir := range l.out {
+		c.out[e] = dir
+	}
+	c.in = make(map[edge]int, len(l.in))
+	for e, dir := range l.in {
+		c.in[e] = dir
+	}
+	return &c
+}
+
+// neighbour returns the tile next to tile in direction dir or -1 at the edge
+// of the grid.
+func (l *layout) neighbour(tile int, dir int) int {
+	row, col := tile/l.width, tile%l.width
+	switch dir {
+	case up:
+		row--
+	case right:
+		col++
+	case down:
+		row++
+	case left:
+		col--
+	}
+	if row < 0 || row >= l.height || col < 0 || col >= l.width {
+		return -1
+	}
+	return row*l.width + col
+}
+
+func (l *layout) free(tile int) bool {
+	return tile >= 0 && !l.damaged[tile] && !l.used[tile]
+}
+
+func (l *layout) distance(a int, b int) int {
+	return abs(a/l.width-b/l.width) + abs(a%l.width-b%l.width)
+}
+
+// place puts ops reading from an input under the stream, ops writing to an
+// output above it and every other op on the closest free node to its
+// neighbours that it can be routed to.
+func (l *layout) place(ops []*op) error {
+	for _, o := range ops {
+		var stream *types.Stream
+		var dir int
+		switch {
+		case o.input != nil:
+			stream, dir = o.input, up
+			o.tile = int(stream.Position)
+		case o.output != nil:
+			stream, dir = o.output, down
+			o.tile = (l.height-1)*l.width + int(stream.Position)
+		default:
+			continue
+		}
+		if o.tile >= len(l.used) || l.damaged[o.tile] {
+			return fmt.Errorf("line %d: the node of stream %s is damaged", o.line, stream.Name)
+		}
+		if l.used[o.tile] {
+			return fmt.Errorf("line %d: the node of stream %s is taken", o.line, stream.Name)
+		}
+		l.used[o.tile] = true
+		l.ports[o.tile][dir] = true
+	}
+
+	// Routes of the same length depend on the order the directions are tried
+	// in, another order may fit where one does not.
+	for _, dirs := range permutations([]int{up, right, down, left}) {
+		budget := searchBudget
+		l.dirs = dirs
+		if result, ok := l.search(ops, &budget); ok {
+			*l = *result
+			return nil
+		}
+	}
+	return errors.New("program does not fit on the grid")
+}
+
+func permutations(items []int) [][]int {
+	if len(items) <= 1 {
+		return [][]int{items}
+	}
+	result := make([][]int, 0)
+	for i, item := range items {
+		rest := append(append([]int{}, items[:i]...), items[i+1:]...)
+		for _, p := range permutations(rest) {
+			result = append(result, append([]int{item}, p...))
+		}
+	}
+	return result
+}
+
+// search places ops one by one and goes back to the previous op when the
+// rest does not fit, giving up after budget steps.
+func (l *layout) search(ops []*op, budget *int) (*layout, bool) {
+	if len(ops) == 0 {
+		return l, true
+	}
+	if *budget == 0 {
+		return nil, false
+	}
+	*budget--
+
+	o := ops[0]
+	if pinned(o) {
+		routed, ok := l.connect(o)
+		if !ok {
+			return nil, false
+		}
+		return routed.search(ops[1:], budget)
+	}
+	for _, tile := range l.candidates(o) {
+		c := l.clone()
+		o.tile = tile
+		c.used[tile] = true
+		if routed, ok := c.connect(o); ok {
+			if result, ok := routed.search(ops[1:], budget); ok {
+				return result, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// candidates returns the free nodes with enough free neighbours for the
+// operands and users of o, the closest to the ones already placed first.
+func (l *layout) candidates(o *op) []int {
+	needed := len(neighbours(o))
+	tiles := make([]int, 0)
+	for tile := range l.used {
+		if !l.free(tile) {
+			continue
+		}
+		count := 0
+		for dir := range constants.PortsNumber {
+			if next := l.neighbour(tile, dir); next >= 0 && !l.damaged[next] {
+				count++
+			}
+		}
+		if count >= needed {
+			tiles = append(tiles, tile)
+		}
+	}
+
+	cost := func(tile int) int {
+		sum := 0
+		for _, n := range neighbours(o) {
+			if n != nil && (n.index < o.index || pinned(n)) {
+				sum += l.distance(tile, n.tile)
+			}
+		}
+		return sum
+	}
+	sort.SliceStable(tiles, func(i, j int) bool {
+		return cost(tiles[i]) < cost(tiles[j])
+	})
+	return tiles
+}
+
+// connect routes values from every operand of o to o on a copy of the
+// layout. When o is not pinned to a stream, values are routed to the users
+// that are as well, which tells whether the node of o suits them. As a route
+// may block the others, every order of the routes is tried.
+func (l *layout) connect(o *op) (*layout, bool) {
+	edges := make([]edge, 0)
+	for _, p := range producers(o) {
+		if _, ok := l.out[edge{p, o}]; !ok && !p.fused {
+			edges = append(edges, edge{p, o})
+		}
+	}
+	for _, user := range o.users {
+		if _, ok := l.out[edge{o, user}]; !ok && !user.fused && !pinned(o) && pinned(user) {
+			edges = append(edges, edge{o, user})
+		}
+	}
+	return l.connectEdges(edges)
+}
+
+func (l *layout) connectEdges(edges []edge) (*layout, bool) {
+	if len(edges) == 0 {
+		return l, true
+	}
+	for i, e := range edges {
+		c := l.clone()
+		if !c.route(e.from, e.to) {
+			continue
+		}
+		rest := append(append([]edge{}, edges[:i]...), edges[i+1:]...)
+		if result, ok := c.connectEdges(rest); ok {
+			return result, true
+		}
+	}
+	return nil, false
+}
+
+// route finds the shortest path of free nodes from the node of from to the
+// node of to and makes them pass the values on.
+func (l *layout) route(from *op, to *op) bool {
+	type step struct {
+		prev int
+		dir  int
+	}
+	steps := make(map[int]step)
+	queue := make([]int, 0)
+	end, endDir := -1, -1
+
+	visit := func(tile int, dir int) {
+		next := l.neighbour(tile, dir)
+		switch {
+		case end >= 0 || next < 0 || l.ports[tile][dir]:
+		case next == to.tile && !l.ports[next][(dir+2)%constants.PortsNumber]:
+			end, endDir = tile, dir
+		case l.free(next) && next != from.tile:
+			if _, ok := steps[next]; !ok {
+				steps[next] = step{prev: tile, dir: dir}
+				queue = append(queue, next)
+			}
+		}
+	}
+	for _, dir := range l.dirs {
+		visit(from.tile, dir)
+	}
+	for len(queue) > 0 && end < 0 {
+		tile := queue[0]
+		queue = queue[1:]
+		for _, dir := range l.dirs {
+			visit(tile, dir)
+		}
+	}
+	if end < 0 {
+		return false
+	}
+
+	l.in[edge{from, to}] = (endDir + 2) % constants.PortsNumber
+	l.ports[to.tile][(endDir+2)%constants.PortsNumber] = true
+	out := endDir
+	for tile := end; tile != from.tile; {
+		s := steps[tile]
+		in := (s.dir + 2) % constants.PortsNumber
+		l.used[tile] = true
+		l.ports[tile][in], l.ports[tile][out] = true, true
+		l.nodes[tile] = []string{"MOV " + portNames[in] + ", " + portNames[out]}
+		tile, out = s.prev, s.dir
+	}
+	l.out[edge{from, to}] = out
+	l.ports[from.tile][out] = true
+	return true
+}
+
+// code returns the code of the node of o with the ports of the layout.
+func (l *layout) code(o *op) []string {
+	src := func(arg operand) string {
+		switch {
+		case arg.op == nil:
+			return strconv.Itoa(arg.value)
+		case arg.op.fused:
+			return portNames[up]
+		}
+		return portNames[l.in[edge{arg.op, o}]]
+	}
+	write := make([]string, 0)
+	for _, user := range o.users {
+		port := portNames[down]
+		if !user.fused {
+			port = portNames[l.out[edge{o, user}]]
+		}
+		for range reads(user, o) {
+			write = append(write, "MOV ACC, "+port)
+		}
+	}
+	return code(o, src, write)
+}
+
+func pinned(o *op) bool {
+	return o.input != nil || o.output != nil
+}
@@ -0,0 +1,258 @@
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type expr interface{}
+
+type number struct {
+	value int
+}
+
+type name struct {
+	name string
+}
+
+type unary struct {
+	x expr
+}
+
+type binary struct {
+	op string
+	x  expr
+	y  expr
+}
+
+type call struct {
+	fn   string
+	args []expr
+}
+
+// statement assigns value to target. count is the number of times a
+// REPEAT statement writes the value, nil for other statements.
+type statement struct {
+	line   int
+	target string
+	value  expr
+	count  expr
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+var binaryOps = [][]string{
+	{"==", "!=", "<", ">", "<=", ">="},
+	{"+", "-"},
+	{"*"},
+}
+
+// parse splits src into statements, one per line or separated by ";". Names
+// are upper case, as stream names are.
+func parse(src string) ([]statement, error) {
+	statements := make([]statement, 0)
+	for i, line := range strings.Split(src, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		for _, text := range strings.Split(line, ";") {
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			st, err := parseStatement(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			st.line = i + 1
+			statements = append(statements, st)
+		}
+	}
+	return statements, nil
+}
+
+func parseStatement(text string) (statement, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		return statement{}, err
+	}
+	p := &parser{tokens: tokens}
+	var count expr
+	// REPEAT is a variable when it is assigned to
+	if len(tokens) > 1 && tokens[0] == "REPEAT" && tokens[1] != "=" {
+		p.pos++
+		if count, err = p.expr(0); err != nil {
+			return statement{}, err
+		}
+		if err := p.expect(":"); err != nil {
+			return statement{}, err
+		}
+	}
+	rest := p.tokens[p.pos:]
+	if len(rest) < 3 || !isName(rest[0]) || rest[1] != "=" {
+		if count != nil {
+			return statement{}, fmt.Errorf("expected REPEAT EXPRESSION: NAME = EXPRESSION")
+		}
+		return statement{}, fmt.Errorf("expected NAME = EXPRESSION")
+	}
+
+	p.pos += 2
+	value, err := p.expr(0)
+	if err != nil {
+		return statement{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return statement{}, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	return statement{target: rest[0], value: value, count: count}, nil
+}
+
+func tokenize(text string) ([]string, error) {
+	tokens := make([]string, 0)
+	for i := 0; i < len(text); {
+		c := rune(text[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(text) && unicode.IsDigit(rune(text[j])) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(text) && (unicode.IsLetter(rune(text[j])) || unicode.IsDigit(rune(text[j])) ||
+				text[j] == '_' || text[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, strings.ToUpper(text[i:j]))
+			i = j
+		case strings.ContainsRune("=!<>", c) && i+1 < len(text) && text[i+1] == '=':
+			tokens = append(tokens, text[i:i+2])
+			i += 2
+		case strings.ContainsRune("=<>+-*(),:", c):
+			tokens = append(tokens, text[i:i+1])
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func isName(token string) bool {
+	c := rune(token[0])
+	return unicode.IsLetter(c) || c == '_'
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) expect(token string) error {
+	if p.peek() != token {
+		if p.peek() == "" {
+			return fmt.Errorf("expected %s at the end of the line", token)
+		}
+		return fmt.Errorf("expected %s, got %s", token, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// expr parses binary operators from the given level of precedence up.
+// Comparisons do not chain.
+func (p *parser) expr(level int) (expr, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	x, err := p.expr(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for contains(binaryOps[level], p.peek()) {
+		op := p.peek()
+		p.pos++
+		y, err := p.expr(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+		if level == 0 {
+			break
+		}
+	}
+	return x, nil
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.peek() == "-" {
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("expected an expression at the end of the line")
+	case token == "(":
+		p.pos++
+		x, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case unicode.IsDigit(rune(token[0])):
+		p.pos++
+		value, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, err
+		}
+		return number{value: value}, nil
+	case isName(token):
+		p.pos++
+		if p.peek() != "(" {
+			return name{name: token}, nil
+		}
+		p.pos++
+		args := make([]expr, 0, 2)
+		for p.peek() != ")" {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		p.pos++
+		return call{fn: token, args: args}, nil
+	}
+	return nil, fmt.Errorf("unexpected %s", token)
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AppName      = "tis-100"
+	FileName     = "config.json"
+	DefaultTheme = "default"
+)
+
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return errors.New("duration must be a string like \"100ms\"")
+	}
+	value, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	d.Duration = value
+	return nil
+}
+
+type Theme struct {
+	Text       string `json:"text"`
+	Background string `json:"background"`
+	Opcode     string `json:"opcode"`
+	Register   string `json:"register"`
+	Port       string `json:"port"`
+	Label      string `json:"label"`
+	Literal    string `json:"literal"`
+	Comment    string `json:"comment"`
+	Invalid    string `json:"invalid"`
+	Border     string `json:"border"`
+	Focus      string `json:"focus"`
+	Damaged    string `json:"damaged"`
+	Error      string `json:"error"`
+	Warning    string `json:"warning"`
+	Status     string `json:"status"`
+}
+
+type Config struct {
+	Theme      string              `json:"theme"`
+	Themes     map[string]Theme    `json:"themes"`
+	PuzzleDir  string              `json:"puzzle_dir"`
+	SaveDir    string              `json:"save_dir"`
+	TickRate   Duration            `json:"tick_rate"`
+	FastCycles int                 `json:"fast_cycles"`
+	Trusted    bool                `json:"trusted_puzzles"`
+	Keys       map[string][]string `json:"keys"`
+}
+
+var Actions = []string{"up", "down", "select", "open", "save", "next", "prev", "run", "fast", "restart", "profile", "help", "quit"}
+
+// DefaultKeys binds every action to at least one key that is not printable,
+// as printable keys are typed into the node editor instead.
+var DefaultKeys = map[string][]string{
+	"up":      {"up", "k"},
+	"down":    {"down", "j"},
+	"select":  {"enter"},
+	"open":    {"ctrl+o"},
+	"save":    {"ctrl+s"},
+	"next":    {"tab"},
+	"prev":    {"shift+tab"},
+	"run":     {"ctrl+r"},
+	"fast":    {"ctrl+f"},
+	"restart": {"ctrl+n"},
+	"profile": {"ctrl+p"},
+	"help":    {"?", "f1"},
+	"quit":    {"ctrl+c"},
+}
+
+var Themes = map[string]Theme{
+	"default": {
+		Text:     "252",
+		Opcode:   "39",
+		Register: "214",
+		Port:     "78",
+		Label:    "177",
+		Literal:  "222",
+		Comment:  "243",
+		Invalid:  "196",
+		Border:   "250",
+		Focus:    "231",
+		Damaged:  "160",
+		Error:    "196",
+		Warning:  "220",
+		Status:   "245",
+	},
+	"original": {
+		Text:       "#FFFFFF",
+		Background: "#000000",
+		Opcode:     "#FFFFFF",
+		Register:   "#FFFFFF",
+		Port:       "#FFFFFF",
+		Label:      "#FFFFFF",
+		Literal:    "#FFFFFF",
+		Comment:    "#7F7F7F",
+		Invalid:    "#A10000",
+		Border:     "#BFBFBF",
+		Focus:      "#FFFFFF",
+		Damaged:    "#A10000",
+		Error:      "#A10000",
+		Warning:    "#BFBFBF",
+		Status:     "#BFBFBF",
+	},
+	"high-contrast": {
+		Text:       "#FFFFFF",
+		Background: "#000000",
+		Opcode:     "#00FFFF",
+		Register:   "#FFFF00",
+		Port:       "#00FF00",
+		Label:      "#FF00FF",
+		Literal:    "#FFFFFF",
+		Comment:    "#C0C0C0",
+		Invalid:    "#FF0000",
+		Border:     "#FFFFFF",
+		Focus:      "#FFFF00",
+		Damaged:    "#FF0000",
+		Error:      "#FF0000",
+		Warning:    "#FFFF00",
+		Status:     "#FFFFFF",
+	},
+}
+
+var colorRegexp = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+func Default() *Config {
+	keys := make(map[string][]string, len(DefaultKeys))
+	for action, bound := range DefaultKeys {
+		keys[action] = append([]string{}, bound...)
+	}
+	return &Config{
+		Theme:      DefaultTheme,
+		Themes:     make(map[string]Theme),
+		TickRate:   Duration{100 * time.Millisecond},
+		FastCycles: 50,
+		Keys:       keys,
+	}
+}
+
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find config directory: %w", err)
+	}
+	return filepath.Join(dir, AppName, FileName), nil
+}
+
+// Load reads the config file from the user config directory. A missing file
+// is not an error, the default config is returned instead.
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		cfg := Default()
+		if err = cfg.Validate(); err != nil {
+			return nil, err
+		}
+		cfg.normalize()
+		return cfg, nil
+	}
+	return LoadFile(path)
+}
+
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file %s: %w", path, err)
+	}
+
+	cfg := Default()
+	if err = json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+	if err = cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s:\n%w", path, err)
+	}
+	cfg.normalize()
+	return cfg, nil
+}
+
+func (c *Config) Validate() error {
+	errs := make([]error, 0)
+
+	if _, ok := c.theme(c.Theme); !ok {
+		errs = append(errs, fmt.Errorf("theme: unknown theme %q", c.Theme))
+	}
+	names := make([]string, 0, len(c.Themes))
+	for name := range c.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		colors := c.Themes[name].colors()
+		fields := make([]string, 0, len(colors))
+		for field := range colors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if color := colors[field]; color != "" && !validColor(color) {
+				errs = append(errs, fmt.Errorf("themes.%s.%s: invalid color %q", name, field, color))
+			}
+		}
+	}
+
+	if _, err := resolveDir(c.PuzzleDir); err != nil {
+		errs = append(errs, fmt.Errorf("puzzle_dir: %w", err))
+	}
+	if _, err := resolveDir(c.SaveDir); c.SaveDir != "" && err != nil {
+		errs = append(errs, fmt.Errorf("save_dir: %w", err))
+	}
+
+	if c.TickRate.Duration <= 0 {
+		errs = append(errs, errors.New("tick_rate: must be positive"))
+	}
+	if c.FastCycles <= 0 {
+		errs = append(errs, errors.New("fast_cycles: must be positive"))
+	}
+	errs = append(errs, c.validateKeys()...)
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateKeys() []error {
+	errs := make([]error, 0)
+
+	actions := make([]string, 0, len(c.Keys))
+	for action := range c.Keys {
+		if _, ok := DefaultKeys[action]; !ok {
+			actions = append(actions, action)
+		}
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		errs = append(errs, fmt.Errorf("keys.%s: unknown action", action))
+	}
+
+	boundTo := make(map[string]string)
+	for _, action := range Actions {
+		bound := c.Keys[action]
+		if len(bound) == 0 {
+			errs = append(errs, fmt.Errorf("keys.%s: no keys bound", action))
+		}
+		for _, key := range bound {
+			if key == "" {
+				errs = append(errs, fmt.Errorf("keys.%s: empty key", action))
+			} else if other, ok := boundTo[key]; ok {
+				errs = append(errs, fmt.Errorf("keys.%s: %q is already bound to %s", action, key, other))
+			} else {
+				boundTo[key] = action
+			}
+		}
+	}
+
+	return errs
+}
+
+// normalize replaces the directories of a valid config with the ones they
+// resolve to. The save directory defaults to the puzzle directory.
+func (c *Config) normalize() {
+	c.PuzzleDir, _ = resolveDir(c.PuzzleDir)
+	if c.SaveDir == "" {
+		c.SaveDir = c.PuzzleDir
+	} else {
+		c.SaveDir, _ = resolveDir(c.SaveDir)
+	}
+}
+
+// CurrentTheme returns the selected theme, with unset colors taken from the
+// default theme.
+func (c *Config) CurrentTheme() Theme {
+	theme, _ := c.theme(c.Theme)
+	return theme
+}
+
+func (c *Config) theme(name string) (Theme, bool) {
+	base := Themes[DefaultTheme]
+	if theme, ok := c.Themes[name]; ok {
+		return theme.merge(base), true
+	}
+	if theme, ok := Themes[name]; ok {
+		return theme.merge(base), true
+	}
+	return base, false
+}
+
+func (t Theme) colors() map[string]string {
+	return map[string]string{
+		"text":       t.Text,
+		"background": t.Background,
+		"opcode":     t.Opcode,
+		"register":   t.Register,
+		"port":       t.Port,
+		"label":      t.Label,
+		"literal":    t.Literal,
+		"comment":    t.Comment,
+		"invalid":    t.Invalid,
+		"border":     t.Border,
+		"focus":      t.Focus,
+		"damaged":    t.Damaged,
+		"error":      t.Error,
+		"warning":    t.Warning,
+		"status":     t.Status,
+	}
+}
+
+func (t Theme) merge(base Theme) Theme {
+	fields := []*string{
+		&t.Text, &t.Opcode, &t.Register, &t.Port, &t.Label, &t.Literal, &t.Comment,
+		&t.Invalid, &t.Border, &t.Focus, &t.Damaged, &t.Error, &t.Warning, &t.Status,
+	}
+	baseFields := []string{
+		base.Text, base.Opcode, base.Register, base.Port, base.Label, base.Literal, base.Comment,
+		base.Invalid, base.Border, base.Focus, base.Damaged, base.Error, base.Warning, base.Status,
+	}
+	for i, field := range fields {
+		if *field == "" {
+			*field = baseFields[i]
+		}
+	}
+	return t
+}
+
+func validColor(color string) bool {
+	if colorRegexp.MatchString(color) {
+		return true
+	}
+	num, err := strconv.Atoi(color)
+	return err == nil && num >= 0 && num <= 255
+}
+
+func resolveDir(dir string) (string, error) {
+	if dir == "" {
+		return os.Getwd()
+	}
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return dir, err
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return dir, err
+	}
+	if !info.IsDir() {
+		return dir, fmt.Errorf("%s is not a directory", dir)
+	}
+	return dir, nil
+}
@@ -0,0 +1,139 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FranChesK0/tis-100/internal/config"
+)
+
+/* TESTS */
+
+// LoadFile
+func TestLoadFileWithCorrectConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := SetupConfig(t, dir, `{
+		"theme": "mine",
+		"themes": { "mine": { "opcode": "#FF0000" } },
+		"puzzle_dir": "`+dir+`",
+		"tick_rate": "250ms",
+		"fast_cycles": 10
+	}`)
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if cfg.TickRate.Duration != 250*time.Millisecond {
+		t.Errorf("wrong tick rate. expected: 250ms, got: %s", cfg.TickRate)
+	}
+	if cfg.SaveDir != dir {
+		t.Errorf("wrong save dir. expected: %s, got: %s", dir, cfg.SaveDir)
+	}
+	theme := cfg.CurrentTheme()
+	if theme.Opcode != "#FF0000" {
+		t.Errorf("wrong opcode color. expected: #FF0000, got: %s", theme.Opcode)
+	}
+	if theme.Text != config.Themes[config.DefaultTheme].Text {
+		t.Error("unset theme colors are not taken from default theme")
+	}
+}
+
+func TestLoadFileWithWrongValues(t *testing.T) {
+	dir := t.TempDir()
+	path := SetupConfig(t, dir, `{
+		"theme": "unknown",
+		"themes": { "mine": { "port": "green" } },
+		"save_dir": "/notexistingdirectory",
+		"fast_cycles": 0
+	}`)
+
+	_, err := config.LoadFile(path)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErrs := []string{
+		"theme: unknown theme \"unknown\"",
+		"themes.mine.port: invalid color \"green\"",
+		"save_dir: stat /notexistingdirectory",
+		"fast_cycles: must be positive",
+	}
+	for _, expectedErr := range expectedErrs {
+		if !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("missing error. expected: %s, got: %s", expectedErr, err.Error())
+		}
+	}
+}
+
+func TestLoadFileWithWrongDuration(t *testing.T) {
+	path := SetupConfig(t, t.TempDir(), `{ "tick_rate": 100 }`)
+
+	_, err := config.LoadFile(path)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "duration must be a string"
+	if !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+func TestLoadFileWithRemappedKeys(t *testing.T) {
+	path := SetupConfig(t, t.TempDir(), `{ "keys": { "save": ["f2"], "open": ["f3", "ctrl+o"] } }`)
+
+	cfg, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(cfg.Keys["save"], []string{"f2"}) {
+		t.Errorf("wrong save keys. expected: [f2], got: %v", cfg.Keys["save"])
+	}
+	if !reflect.DeepEqual(cfg.Keys["run"], config.DefaultKeys["run"]) {
+		t.Errorf("wrong run keys. expected: %v, got: %v", config.DefaultKeys["run"], cfg.Keys["run"])
+	}
+}
+
+func TestLoadFileWithConflictingKeys(t *testing.T) {
+	path := SetupConfig(t, t.TempDir(), `{ "keys": { "save": ["ctrl+o"], "quit": [], "jump": ["j"] } }`)
+
+	_, err := config.LoadFile(path)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErrs := []string{
+		"keys.jump: unknown action",
+		"keys.save: \"ctrl+o\" is already bound to open",
+		"keys.quit: no keys bound",
+	}
+	for _, expectedErr := range expectedErrs {
+		if !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("missing error. expected: %s, got: %s", expectedErr, err.Error())
+		}
+	}
+}
+
+// Validate
+func TestValidateKeepsConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.SaveDir = "~/"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cfg.PuzzleDir != "" || cfg.SaveDir != "~/" {
+		t.Errorf("config is changed. puzzle dir: %q, save dir: %q", cfg.PuzzleDir, cfg.SaveDir)
+	}
+}
+
+/* UTILS */
+func SetupConfig(t *testing.T, dir string, content string) string {
+	path := filepath.Join(dir, config.FileName)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -7,10 +7,19 @@ const (
 	MaxACC                = 999
 	MinACC                = -999
 	StreamTypesNumber     = 2
-	IOPositionsNumber     = 4
+	DefaultGridWidth      = 4
+	DefaultGridHeight     = 3
+	MaxGridWidth          = 12
+	MaxGridHeight         = 12
+	IOPositionsNumber     = DefaultGridWidth
 	MaxStreamValuesLength = 30
-	NodesNumber           = 12
+	NodesNumber           = DefaultGridWidth * DefaultGridHeight
 	NodeTypesNumber       = 2
+	PortsNumber           = 4
+	MaxNodeLines          = 15
+	MaxLineLength         = 18
+	MaxCycles             = 100000
+	ScoresFileName        = "scores.json"
 )
 
 /* ENUMS */
@@ -23,3 +32,10 @@ const (
 	COMPUTE types.NodeType = iota
 	DAMAGED
 )
+
+const (
+	UP types.Port = iota
+	RIGHT
+	DOWN
+	LEFT
+)
@@ -0,0 +1,33 @@
+package emu
+
+import "fmt"
+
+// AssemblyError is a problem found in the code of a node. Errors keep the code
+// from running, warnings only point at code that is likely wrong.
+type AssemblyError struct {
+	Node     uint8
+	Line     int
+	Message  string
+	Severity Severity
+}
+
+func (e *AssemblyError) Error() string {
+	if e.Severity == WARNING {
+		return fmt.Sprintf("node %d, line %d: warning: %s", e.Node+1, e.Line+1, e.Message)
+	}
+	return fmt.Sprintf("node %d, line %d: %s", e.Node+1, e.Line+1, e.Message)
+}
+
+func (s Severity) String() string {
+	return [...]string{"error", "warning"}[s]
+}
+
+// CheckCode assembles the lines of a single node and returns every error
+// found instead of stopping at the first one.
+func CheckCode(lines []string) []*AssemblyError {
+	ic := NewInputCode()
+	for _, line := range lines {
+		ic.AddLine(line)
+	}
+	return NewNode().parseCode(&ic, true)
+}
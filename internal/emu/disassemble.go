@@ -0,0 +1,108 @@
+package emu
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var operationNames = [...]string{
+	"MOV", "SAV", "SWP", "SUB", "ADD", "NOP", "NEG", "JEZ", "JMP", "JNZ", "JGZ", "JLZ", "JRO", "OUT",
+}
+
+var directionNames = [...]string{"UP", "RIGHT", "DOWN", "LEFT", "NIL", "ACC", "ANY", "LAST", "BAK"}
+
+func (o Operation) String() string {
+	if int(o) >= len(operationNames) {
+		return fmt.Sprintf("Operation(%d)", o)
+	}
+	return operationNames[o]
+}
+
+func (d LocationDirection) String() string {
+	if int(d) >= len(directionNames) {
+		return fmt.Sprintf("LocationDirection(%d)", d)
+	}
+	return directionNames[d]
+}
+
+func (t LocationType) String() string {
+	switch t {
+	case NUMBER:
+		return "NUMBER"
+	case ADDRESS:
+		return "ADDRESS"
+	}
+	return fmt.Sprintf("LocationType(%d)", t)
+}
+
+// String shows both fields, as only the instruction knows which of them is
+// used.
+func (l Location) String() string {
+	return fmt.Sprintf("{%d %s}", l.Number, l.Direction)
+}
+
+// String returns the instruction as assembly. Jumps go to the label
+// Disassemble gives their target.
+func (ins Instruction) String() string {
+	switch ins.Operation {
+	case MOV:
+		return fmt.Sprintf("MOV %s, %s", operand(ins.SrcType, ins.Src), operand(ins.DestType, ins.Dest))
+	case JEZ, JMP, JNZ, JGZ, JLZ:
+		return fmt.Sprintf("%s %s", ins.Operation, label(ins.Src.Number))
+	case SUB, ADD, JRO:
+		return fmt.Sprintf("%s %s", ins.Operation, operand(ins.SrcType, ins.Src))
+	}
+	return ins.Operation.String()
+}
+
+// Disassemble turns assembled instructions back into lines of assembly,
+// labelling every instruction a jump goes to. Jumps out of the code go to
+// the first instruction, as they do when run.
+func Disassemble(instructions []*Instruction) []string {
+	targets := make(map[int16]bool)
+	for _, ins := range instructions {
+		if isJump(ins.Operation) {
+			targets[jumpTarget(ins.Src.Number, len(instructions))] = true
+		}
+	}
+
+	lines := make([]string, 0, len(instructions))
+	for i, ins := range instructions {
+		line := *ins
+		if isJump(ins.Operation) {
+			line.Src.Number = jumpTarget(ins.Src.Number, len(instructions))
+		}
+		text := line.String()
+		if targets[int16(i)] {
+			text = label(int16(i)) + ": " + text
+		}
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+func operand(locType LocationType, loc Location) string {
+	if locType == NUMBER {
+		return strconv.Itoa(int(loc.Number))
+	}
+	return loc.Direction.String()
+}
+
+func label(pos int16) string {
+	return "L" + strconv.Itoa(int(pos))
+}
+
+func isJump(op Operation) bool {
+	switch op {
+	case JEZ, JMP, JNZ, JGZ, JLZ:
+		return true
+	}
+	return false
+}
+
+func jumpTarget(pos int16, length int) int16 {
+	if pos < 0 || int(pos) >= length {
+		return 0
+	}
+	return pos
+}
@@ -0,0 +1,124 @@
+package emu_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/emu"
+)
+
+/* TESTS */
+
+// Disassemble
+func TestDisassemble(t *testing.T) {
+	instructions := assemble(t, []string{"s: mov up, acc", "", "JEZ END # skip", "add -1", "loop:", "END: jmp S"})
+
+	expected := []string{"L0: MOV UP, ACC", "JEZ L4", "ADD -1", "NOP", "L4: JMP L0"}
+	if got := emu.Disassemble(instructions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong lines. expected: %q, got: %q", expected, got)
+	}
+}
+
+func TestDisassembleWithJumpOutOfCode(t *testing.T) {
+	instructions := []*emu.Instruction{
+		{Operation: emu.NOP},
+		{Operation: emu.JMP, SrcType: emu.NUMBER, Src: emu.Location{Number: 7}},
+	}
+
+	expected := []string{"L0: NOP", "JMP L0"}
+	if got := emu.Disassemble(instructions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong lines. expected: %q, got: %q", expected, got)
+	}
+	if instructions[1].Src.Number != 7 {
+		t.Errorf("instruction is changed: %+v", instructions[1])
+	}
+}
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	numbers := []int16{-999, -1, 0, 42, 999}
+	sources := []emu.LocationDirection{emu.UP, emu.RIGHT, emu.DOWN, emu.LEFT, emu.NIL, emu.ACC, emu.ANY, emu.LAST}
+
+	instructions := make([]*emu.Instruction, 0)
+	add := func(ins emu.Instruction) {
+		instructions = append(instructions, &ins)
+	}
+	for _, op := range []emu.Operation{emu.SAV, emu.SWP, emu.NOP, emu.NEG, emu.OUT} {
+		add(emu.Instruction{Operation: op})
+	}
+	for _, op := range []emu.Operation{emu.MOV, emu.SUB, emu.ADD, emu.JRO} {
+		srcs := make([]emu.Instruction, 0)
+		for _, number := range numbers {
+			srcs = append(srcs, emu.Instruction{Operation: op, SrcType: emu.NUMBER, Src: emu.Location{Number: number}})
+		}
+		for _, dir := range sources {
+			srcs = append(srcs, emu.Instruction{Operation: op, SrcType: emu.ADDRESS, Src: emu.Location{Direction: dir}})
+		}
+		for _, ins := range srcs {
+			if op != emu.MOV {
+				add(ins)
+				continue
+			}
+			for _, dir := range sources {
+				ins.DestType, ins.Dest = emu.ADDRESS, emu.Location{Direction: dir}
+				add(ins)
+			}
+		}
+	}
+	for i, op := range []emu.Operation{emu.JEZ, emu.JMP, emu.JNZ, emu.JGZ, emu.JLZ} {
+		add(emu.Instruction{Operation: op, SrcType: emu.NUMBER, Src: emu.Location{Number: int16(i * 30)}})
+	}
+
+	lines := emu.Disassemble(instructions)
+	if got := assemble(t, lines); !reflect.DeepEqual(got, instructions) {
+		for i := range instructions {
+			if i >= len(got) || !reflect.DeepEqual(got[i], instructions[i]) {
+				t.Fatalf("wrong instruction %d of %q. expected: %+v", i, lines[i], *instructions[i])
+			}
+		}
+		t.Fatalf("wrong instructions number. expected: %d, got: %d", len(instructions), len(got))
+	}
+	if got := emu.Disassemble(assemble(t, lines)); !reflect.DeepEqual(got, lines) {
+		t.Errorf("wrong lines. expected: %q, got: %q", lines, got)
+	}
+}
+
+// String
+func TestString(t *testing.T) {
+	cases := []struct {
+		value    fmt.Stringer
+		expected string
+	}{
+		{emu.JRO, "JRO"},
+		{emu.OUT, "OUT"},
+		{emu.Operation(99), "Operation(99)"},
+		{emu.LAST, "LAST"},
+		{emu.BAK, "BAK"},
+		{emu.LocationDirection(20), "LocationDirection(20)"},
+		{emu.ADDRESS, "ADDRESS"},
+		{emu.Location{Number: 3, Direction: emu.ACC}, "{3 ACC}"},
+		{emu.Instruction{Operation: emu.MOV, SrcType: emu.NUMBER, Src: emu.Location{Number: -5}, DestType: emu.ADDRESS, Dest: emu.Location{Direction: emu.ANY}}, "MOV -5, ANY"},
+		{emu.Instruction{Operation: emu.SUB, SrcType: emu.ADDRESS, Src: emu.Location{Direction: emu.LEFT}}, "SUB LEFT"},
+		{emu.Instruction{Operation: emu.JGZ, SrcType: emu.NUMBER, Src: emu.Location{Number: 2}}, "JGZ L2"},
+		{emu.Instruction{Operation: emu.SWP}, "SWP"},
+	}
+	for _, c := range cases {
+		if got := c.value.String(); got != c.expected {
+			t.Errorf("wrong string. expected: %s, got: %s", c.expected, got)
+		}
+	}
+}
+
+/* UTILS */
+func assemble(t *testing.T, lines []string) []*emu.Instruction {
+	t.Helper()
+	ic := emu.NewInputCode()
+	for _, line := range lines {
+		ic.AddLine(line)
+	}
+	n := emu.NewNode()
+	if err := n.ParseCode(&ic); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return n.Instructions
+}
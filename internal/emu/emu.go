@@ -4,6 +4,9 @@ type (
 	Operation         uint8
 	LocationType      uint8
 	LocationDirection uint8
+	PortAccess        uint8
+	NodeMode          uint8
+	Severity          uint8
 )
 
 type Location struct {
@@ -50,4 +53,23 @@ const (
 	ACC
 	ANY
 	LAST
+	BAK
+)
+
+const (
+	READWRITE PortAccess = iota
+	READONLY
+	WRITEONLY
+)
+
+const (
+	IDLE NodeMode = iota
+	RUN
+	READ
+	WRTE
+)
+
+const (
+	ERROR Severity = iota
+	WARNING
 )
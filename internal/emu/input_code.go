@@ -1,14 +1,16 @@
 package emu
 
 type InputCode struct {
-	Lines  []string
-	Labels map[string]uint8
+	Lines       []string
+	Labels      map[string]uint8
+	LineNumbers []int
 }
 
 func NewInputCode() InputCode {
 	return InputCode{
-		Lines:  make([]string, 0),
-		Labels: make(map[string]uint8),
+		Lines:       make([]string, 0),
+		Labels:      make(map[string]uint8),
+		LineNumbers: make([]int, 0),
 	}
 }
 
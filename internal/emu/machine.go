@@ -0,0 +1,672 @@
+package emu
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+)
+
+// Machine is a compiled form of a loaded Program that runs the same way but
+// faster. Instructions of all nodes are kept in one slice and are specialized
+// for their operands when compiled: ports are resolved to the nodes behind
+// them and jumps to positions in the slice, so most instructions take a
+// single switch to run, without the operand decoding of Node.Tick. On the
+// grid of BenchmarkMachineTick a cycle takes about two thirds of the time of
+// a cycle of Program.Tick. Instructions without a specialized form, such as
+// the ones writing to ANY or using LAST, and every instruction while
+// OnTransfer is set, run through the slower step instead.
+//
+// A Machine owns the state of the program while it runs, Sync copies it back
+// to the nodes of the Program. Outputs are shared with the Program.
+type Machine struct {
+	// OnTransfer is called for every value that goes from one node to another.
+	OnTransfer func(from *Node, to *Node, value int16)
+
+	program *Program
+	nodes   []machineNode
+	order   []int32
+	code    []machineOp
+	ptrs    []*Node
+}
+
+// machineNode is the state of a node. pc is the position in the code of the
+// machine of the instruction under the cursor, the code of the node goes from
+// start to end.
+type machineNode struct {
+	pc         int32
+	start      int32
+	end        int32
+	writeTo    int32
+	last       int32
+	acc        int16
+	bak        int16
+	writeValue int16
+	blocked    bool
+	links      [4]int32
+	read       [4]int32
+	write      [4]int32
+	output     *Output
+}
+
+type operandKind uint8
+
+const (
+	opNumber operandKind = iota
+	opNil
+	opACC
+	opPort
+	opAny
+	opLast
+	opInvalid
+)
+
+// opcode is an instruction specialized for its operands. Instructions without
+// a specialized form run through step.
+type opcode uint8
+
+const (
+	codeStep opcode = iota
+	codeNop
+	codeSetACC
+	codeAddValue
+	codeAddACC
+	codeMovPortACC
+	codeMovAnyACC
+	codeAddPort
+	codeSubPort
+	codeMovValuePort
+	codeMovACCPort
+	codeMovPortPort
+	codeSwp
+	codeSav
+	codeNeg
+	codeOut
+	codeJmp
+	codeJez
+	codeJnz
+	codeJgz
+	codeJlz
+	codeJro
+)
+
+// machineOp is a decoded instruction. arg is the number the opcode works
+// with, from is the node a port is read from and to the node a port is
+// written to, or -1 if there is none. to is the position jumped to for jumps.
+type machineOp struct {
+	op       Operation
+	src      operandKind
+	srcPort  uint8
+	dest     operandKind
+	destPort uint8
+	code     opcode
+	value    int16
+	arg      int16
+	from     int32
+	to       int32
+}
+
+var (
+	anyReadOrder  = [4]uint8{uint8(LEFT), uint8(RIGHT), uint8(UP), uint8(DOWN)}
+	anyWriteOrder = [4]uint8{uint8(UP), uint8(LEFT), uint8(RIGHT), uint8(DOWN)}
+)
+
+// Compile builds a Machine from a program with its streams and code loaded.
+func Compile(p *Program) *Machine {
+	m := &Machine{program: p}
+
+	index := make(map[*Node]int32)
+	add := func(n *Node) {
+		if _, ok := index[n]; !ok {
+			index[n] = int32(len(m.ptrs))
+			m.ptrs = append(m.ptrs, n)
+		}
+	}
+	for _, n := range p.Nodes {
+		add(n)
+	}
+	for list := p.NodeList; list != nil; list = list.Next {
+		add(list.Node)
+	}
+	find := func(n *Node) int32 {
+		if n == nil {
+			return -1
+		}
+		add(n)
+		return index[n]
+	}
+
+	m.nodes = make([]machineNode, len(m.ptrs))
+	for i := 0; i < len(m.ptrs); i++ {
+		n := m.ptrs[i]
+		start := int32(len(m.code))
+		mn := machineNode{
+			pc:         start + int32(n.CursorPosition),
+			start:      start,
+			end:        start + int32(len(n.Instructions)),
+			writeTo:    find(n.OutputPort),
+			last:       find(n.Last),
+			acc:        n.ACC,
+			bak:        n.BAK,
+			writeValue: n.OutputValue,
+			blocked:    n.Blocked,
+			output:     n.Output,
+		}
+		for d, port := range n.Ports {
+			mn.links[d] = find(port)
+			mn.read[d], mn.write[d] = mn.links[d], mn.links[d]
+			switch n.Access[d] {
+			case READONLY:
+				mn.write[d] = -1
+			case WRITEONLY:
+				mn.read[d] = -1
+			}
+		}
+		for _, ins := range n.Instructions {
+			o := compileInstruction(ins, len(n.Instructions))
+			specialize(&o, &mn)
+			m.code = append(m.code, o)
+		}
+		// find may have added nodes reachable only through ports
+		if len(m.nodes) < len(m.ptrs) {
+			m.nodes = append(m.nodes, make([]machineNode, len(m.ptrs)-len(m.nodes))...)
+		}
+		m.nodes[i] = mn
+	}
+
+	for list := p.ActiveNodes; list != nil; list = list.Next {
+		m.order = append(m.order, index[list.Node])
+	}
+	return m
+}
+
+func compileInstruction(ins *Instruction, length int) machineOp {
+	op := machineOp{op: ins.Operation, value: ins.Src.Number, from: -1, to: -1}
+	op.src, op.srcPort = compileOperand(ins.SrcType, ins.Src)
+	op.dest, op.destPort = compileOperand(ins.DestType, ins.Dest)
+
+	switch ins.Operation {
+	case JMP, JEZ, JNZ, JGZ, JLZ:
+		if op.value < 0 || int(op.value) >= length {
+			op.value = 0
+		}
+	}
+	return op
+}
+
+func compileOperand(locType LocationType, loc Location) (operandKind, uint8) {
+	if locType == NUMBER {
+		return opNumber, 0
+	}
+	switch loc.Direction {
+	case UP, RIGHT, DOWN, LEFT:
+		return opPort, uint8(loc.Direction)
+	case NIL:
+		return opNil, 0
+	case ACC:
+		return opACC, 0
+	case ANY:
+		return opAny, 0
+	case LAST:
+		return opLast, 0
+	default:
+		return opInvalid, 0
+	}
+}
+
+// specialize sets the opcode of o for the operands it has on node n. o keeps
+// codeStep if it uses ANY, LAST or fails when run.
+func specialize(o *machineOp, n *machineNode) {
+	// the value of a number or NIL source
+	value := o.value
+	if o.src == opNil {
+		value = 0
+	}
+	constant := o.src == opNumber || o.src == opNil
+	if o.src == opPort {
+		o.from = n.read[o.srcPort]
+	}
+	if o.dest == opPort {
+		o.to = n.write[o.destPort]
+	}
+
+	switch o.op {
+	case MOV:
+		switch {
+		case constant && o.dest == opACC:
+			o.code, o.arg = codeSetACC, value
+		case constant && o.dest == opPort:
+			o.code, o.arg = codeMovValuePort, value
+		case o.src == opACC && o.dest == opACC:
+			o.code = codeNop
+		case o.src == opACC && o.dest == opPort:
+			o.code = codeMovACCPort
+		case o.src == opPort && o.dest == opACC:
+			o.code = codeMovPortACC
+		case o.src == opAny && o.dest == opACC:
+			o.code = codeMovAnyACC
+		case o.src == opPort && o.dest == opPort:
+			o.code = codeMovPortPort
+		}
+	case ADD, SUB:
+		switch {
+		case constant && o.op == ADD:
+			o.code, o.arg = codeAddValue, value
+		case constant:
+			o.code, o.arg = codeAddValue, -value
+		case o.src == opACC && o.op == ADD:
+			o.code = codeAddACC
+		case o.src == opACC:
+			o.code, o.arg = codeSetACC, 0
+		case o.src == opPort && o.op == ADD:
+			o.code = codeAddPort
+		case o.src == opPort:
+			o.code = codeSubPort
+		}
+	case JMP, JEZ, JNZ, JGZ, JLZ:
+		switch o.op {
+		case JMP:
+			o.code = codeJmp
+		case JEZ:
+			o.code = codeJez
+		case JNZ:
+			o.code = codeJnz
+		case JGZ:
+			o.code = codeJgz
+		default:
+			o.code = codeJlz
+		}
+		o.to = n.start + int32(o.value)
+	case JRO:
+		o.code = codeJro
+	case SWP:
+		o.code = codeSwp
+	case SAV:
+		o.code = codeSav
+	case NEG:
+		o.code = codeNeg
+	case NOP:
+		o.code = codeNop
+	case OUT:
+		o.code = codeNop
+		if n.output != nil {
+			o.code = codeOut
+		}
+	}
+}
+
+// Tick runs one cycle and reports whether every node is blocked, as
+// Program.Tick does.
+func (m *Machine) Tick() (bool, error) {
+	if m.OnTransfer != nil {
+		return m.tickSteps()
+	}
+
+	nodes, code := m.nodes, m.code
+	allBlocked := true
+	for _, i := range m.order {
+		n := &nodes[i]
+		// a node waiting for its value to be read has nothing to do until then
+		if n.writeTo >= 0 {
+			n.blocked = true
+			continue
+		}
+		if n.pc >= n.end {
+			n.pc = n.start
+		}
+		o := &code[n.pc]
+
+		blocked := false
+		switch o.code {
+		case codeNop:
+			n.pc++
+		case codeSetACC:
+			n.acc = o.arg
+			n.pc++
+		case codeAddValue:
+			n.acc = clampACC(n.acc + o.arg)
+			n.pc++
+		case codeAddACC:
+			n.acc = clampACC(n.acc + n.acc)
+			n.pc++
+		case codeMovPortACC:
+			value, ok := m.take(o.from, i)
+			if ok {
+				n.acc = value
+				n.pc++
+			}
+			blocked = !ok
+		case codeMovAnyACC:
+			blocked = true
+			for _, d := range anyReadOrder {
+				if value, ok := m.take(n.read[d], i); ok {
+					n.acc = value
+					n.last = n.read[d]
+					n.pc++
+					blocked = false
+					break
+				}
+			}
+		case codeAddPort:
+			value, ok := m.take(o.from, i)
+			if ok {
+				n.acc = clampACC(n.acc + value)
+				n.pc++
+			}
+			blocked = !ok
+		case codeSubPort:
+			value, ok := m.take(o.from, i)
+			if ok {
+				n.acc = clampACC(n.acc - value)
+				n.pc++
+			}
+			blocked = !ok
+		case codeMovValuePort:
+			n.offer(o.to, o.arg)
+			blocked = true
+		case codeMovACCPort:
+			n.offer(o.to, n.acc)
+			blocked = true
+		case codeMovPortPort:
+			if value, ok := m.take(o.from, i); ok {
+				n.offer(o.to, value)
+			}
+			blocked = true
+		case codeSwp:
+			n.acc, n.bak = n.bak, n.acc
+			n.pc++
+		case codeSav:
+			n.bak = n.acc
+			n.pc++
+		case codeNeg:
+			n.acc = -n.acc
+			n.pc++
+		case codeOut:
+			n.output.AddValue(n.acc)
+			n.pc++
+		case codeJmp:
+			n.pc = o.to
+		case codeJez:
+			n.pc = branch(n.acc == 0, o.to, n.pc)
+		case codeJnz:
+			n.pc = branch(n.acc != 0, o.to, n.pc)
+		case codeJgz:
+			n.pc = branch(n.acc > 0, o.to, n.pc)
+		case codeJlz:
+			n.pc = branch(n.acc < 0, o.to, n.pc)
+		case codeJro:
+			n.jump(int16(n.pc-n.start) + o.value)
+		default:
+			if err := m.step(i); err != nil {
+				return false, err
+			}
+			blocked = n.blocked
+		}
+		n.blocked = blocked
+		allBlocked = allBlocked && blocked
+	}
+	return allBlocked, nil
+}
+
+// take returns the value node from offers to node i and lets node from go
+// on, or reports that there is none.
+func (m *Machine) take(from int32, i int32) (int16, bool) {
+	if from < 0 {
+		return 0, false
+	}
+	writer := &m.nodes[from]
+	if writer.writeTo != i {
+		return 0, false
+	}
+	value := writer.writeValue
+	writer.writeValue = 0
+	writer.writeTo = -1
+	writer.pc++
+	return value, true
+}
+
+// offer makes the value wait for node to to read it. Without a node the value
+// waits forever.
+func (n *machineNode) offer(to int32, value int16) {
+	if to >= 0 {
+		n.writeTo = to
+		n.writeValue = value
+	}
+}
+
+// branch returns the position after a conditional jump from pc to target.
+func branch(taken bool, target int32, pc int32) int32 {
+	if taken {
+		return target
+	}
+	return pc + 1
+}
+
+// tickSteps is Tick running every instruction through step.
+func (m *Machine) tickSteps() (bool, error) {
+	allBlocked := true
+	for _, i := range m.order {
+		if err := m.step(i); err != nil {
+			return false, err
+		}
+		allBlocked = allBlocked && m.nodes[i].blocked
+	}
+	return allBlocked, nil
+}
+
+// step runs the instruction of node i as Node.Tick does, decoding its
+// operands.
+func (m *Machine) step(i int32) error {
+	n := &m.nodes[i]
+	n.blocked = true
+	if n.writeTo >= 0 {
+		return nil
+	}
+	if n.pc >= n.end {
+		n.pc = n.start
+	}
+	o := &m.code[n.pc]
+
+	switch o.op {
+	case MOV:
+		value, blocked, err := m.read(i, o)
+		if err != nil || blocked {
+			return err
+		}
+		if blocked, err = m.write(i, o, value); err != nil || blocked {
+			return err
+		}
+	case ADD, SUB:
+		value, blocked, err := m.read(i, o)
+		if err != nil || blocked {
+			return err
+		}
+		if o.op == SUB {
+			value = -value
+		}
+		n.acc = clampACC(n.acc + value)
+	case JMP:
+		n.jump(o.value)
+		return nil
+	case JRO:
+		n.jump(int16(n.pc-n.start) + o.value)
+		return nil
+	case JEZ:
+		if n.acc == 0 {
+			n.jump(o.value)
+			return nil
+		}
+	case JGZ:
+		if n.acc > 0 {
+			n.jump(o.value)
+			return nil
+		}
+	case JLZ:
+		if n.acc < 0 {
+			n.jump(o.value)
+			return nil
+		}
+	case JNZ:
+		if n.acc != 0 {
+			n.jump(o.value)
+			return nil
+		}
+	case SWP:
+		n.acc, n.bak = n.bak, n.acc
+	case SAV:
+		n.bak = n.acc
+	case NEG:
+		n.acc = -n.acc
+	case NOP:
+	case OUT:
+		if n.output != nil {
+			n.output.AddValue(n.acc)
+		}
+	default:
+		return fmt.Errorf("unknown operation %s", o.op)
+	}
+
+	n.blocked = false
+	n.pc++
+	return nil
+}
+
+// jump moves the cursor to pos of the code of the node, or to its first
+// instruction if pos is out of the code.
+func (n *machineNode) jump(pos int16) {
+	if pos < 0 || int32(pos) >= n.end-n.start {
+		pos = 0
+	}
+	n.pc = n.start + int32(pos)
+	n.blocked = false
+}
+
+func (m *Machine) read(i int32, o *machineOp) (int16, bool, error) {
+	n := &m.nodes[i]
+	if n.writeTo >= 0 {
+		return 0, false, nil
+	}
+
+	from := int32(-1)
+	switch o.src {
+	case opNumber:
+		return o.value, false, nil
+	case opNil:
+		return 0, false, nil
+	case opACC:
+		return n.acc, false, nil
+	case opPort:
+		from = n.read[o.srcPort]
+	case opAny:
+		for _, d := range anyReadOrder {
+			if port := n.read[d]; port >= 0 && m.nodes[port].writeTo == i {
+				from = port
+				break
+			}
+		}
+	case opLast:
+		from = n.last
+	default:
+		return 0, false, errors.New("unknown direction")
+	}
+
+	if from < 0 {
+		return 0, true, nil
+	}
+	writer := &m.nodes[from]
+	if writer.writeTo != i {
+		return 0, o.src != opLast, nil
+	}
+	value := writer.writeValue
+	writer.writeValue = 0
+	writer.writeTo = -1
+	writer.pc++
+	if o.src == opAny {
+		n.last = from
+	}
+	if m.OnTransfer != nil {
+		m.OnTransfer(m.ptrs[from], m.ptrs[i], value)
+	}
+	return value, false, nil
+}
+
+func (m *Machine) write(i int32, o *machineOp, value int16) (bool, error) {
+	n := &m.nodes[i]
+
+	dest := int32(-1)
+	switch o.dest {
+	case opACC:
+		n.acc = value
+		return false, nil
+	case opPort:
+		dest = n.write[o.destPort]
+	case opAny:
+		for _, d := range anyWriteOrder {
+			if port := n.write[d]; port >= 0 && m.readsFrom(port, i) {
+				dest = port
+				break
+			}
+		}
+	case opLast:
+		dest = n.last
+	case opNil:
+		return false, errors.New("unable to write")
+	default:
+		return false, errors.New("nowhere to write")
+	}
+
+	if dest >= 0 && n.writeTo < 0 {
+		n.writeTo = dest
+		n.writeValue = value
+		if o.dest == opAny {
+			n.last = dest
+		}
+	}
+	return true, nil
+}
+
+func (m *Machine) readsFrom(i int32, neighbour int32) bool {
+	n := &m.nodes[i]
+	if n.start == n.end {
+		return false
+	}
+	pc := n.pc
+	if pc >= n.end {
+		pc = n.start
+	}
+	o := &m.code[pc]
+	if o.op != MOV {
+		return false
+	}
+	return o.src == opAny || (o.src == opPort && n.links[o.srcPort] == neighbour)
+}
+
+// Sync copies the state of the machine back to the nodes of its program.
+func (m *Machine) Sync() {
+	ptr := func(i int32) *Node {
+		if i < 0 {
+			return nil
+		}
+		return m.ptrs[i]
+	}
+	for i, mn := range m.nodes {
+		n := m.ptrs[i]
+		n.CursorPosition = uint8(mn.pc - mn.start)
+		n.ACC = mn.acc
+		n.BAK = mn.bak
+		n.Blocked = mn.blocked
+		n.OutputPort = ptr(mn.writeTo)
+		n.OutputValue = mn.writeValue
+		n.Last = ptr(mn.last)
+	}
+}
+
+func clampACC(value int16) int16 {
+	if value > constants.MaxACC {
+		return constants.MaxACC
+	}
+	if value < constants.MinACC {
+		return constants.MinACC
+	}
+	return value
+}
@@ -0,0 +1,245 @@
+package emu_test
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Compile
+func TestMachineWithRandomPrograms(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for run := range 1000 {
+		streams, code := randomProgram(r)
+		expected := loadProgram(t, streams, code)
+		actual := loadProgram(t, streams, code)
+		m := emu.Compile(actual)
+		// every other run takes the path of traced machines
+		if run%2 == 1 {
+			m.OnTransfer = func(*emu.Node, *emu.Node, int16) {}
+		}
+
+		for cycle := range 300 {
+			expectedBlocked, expectedErr := expected.Tick()
+			actualBlocked, actualErr := m.Tick()
+			m.Sync()
+
+			if fmt.Sprint(expectedErr) != fmt.Sprint(actualErr) || expectedBlocked != actualBlocked {
+				t.Fatalf(
+					"run %d, cycle %d: expected: %t %v, got: %t %v\n%v",
+					run, cycle, expectedBlocked, expectedErr, actualBlocked, actualErr, code.NodesCode,
+				)
+			}
+			if expectedErr != nil {
+				break
+			}
+			if e, a := programState(expected), programState(actual); !reflect.DeepEqual(e, a) {
+				t.Fatalf("run %d, cycle %d: states differ\nexpected: %v\ngot: %v\n%v", run, cycle, e, a, code.NodesCode)
+			}
+		}
+	}
+}
+
+func TestMachineWithConnections(t *testing.T) {
+	streams := []types.Stream{
+		{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{1, 2, 3}},
+		{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{}},
+	}
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	code.NodesCode[0] = []string{"MOV UP, LEFT"}
+	code.NodesCode[8] = []string{"MOV RIGHT, ACC", "ADD 1", "MOV ACC, DOWN"}
+	connections := []types.Connection{
+		{From: 0, FromPort: constants.LEFT, To: 8, ToPort: constants.RIGHT, OneWay: true},
+	}
+
+	p := emu.NewProgram()
+	if err := p.Connect(connections); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadStreams(streams); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadCode(code); err != nil {
+		t.Fatal(err)
+	}
+	m := emu.Compile(p)
+	for range 30 {
+		if _, err := m.Tick(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	expected := []int16{2, 3, 4}
+	if !reflect.DeepEqual(p.Outputs[0].Values, expected) {
+		t.Errorf("wrong output. expected: %v, got: %v", expected, p.Outputs[0].Values)
+	}
+}
+
+func TestMachineWithJump(t *testing.T) {
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	code.NodesCode[0] = []string{"L: JMP L"}
+	m := emu.Compile(loadProgram(t, nil, code))
+	blocked, err := m.Tick()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if blocked {
+		t.Error("node taking a jump is blocked")
+	}
+}
+
+/* BENCHMARKS */
+
+// Tick
+func BenchmarkProgramTick(b *testing.B) {
+	streams, code := benchmarkProgram()
+	p := loadProgram(b, streams, code)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := p.Tick(); err != nil {
+			b.Fatal(err)
+		}
+		resetOutputs(p, i)
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "cycles/s")
+}
+
+func BenchmarkMachineTick(b *testing.B) {
+	streams, code := benchmarkProgram()
+	p := loadProgram(b, streams, code)
+	m := emu.Compile(p)
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := m.Tick(); err != nil {
+			b.Fatal(err)
+		}
+		resetOutputs(p, i)
+	}
+	b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "cycles/s")
+}
+
+/* UTILS */
+func loadProgram(tb testing.TB, streams []types.Stream, code types.ProgramCode) *emu.Program {
+	tb.Helper()
+	p := emu.NewProgram()
+	if err := p.LoadStreams(streams); err != nil {
+		tb.Fatal(err)
+	}
+	if err := p.LoadCode(code); err != nil {
+		tb.Fatal(err)
+	}
+	return p
+}
+
+// resetOutputs empties the outputs every 1024 cycles, so benchmarks measure
+// the cycles rather than the growth of the outputs.
+func resetOutputs(p *emu.Program, cycle int) {
+	if cycle%1024 == 0 {
+		for _, output := range p.Outputs {
+			output.Values = output.Values[:0]
+		}
+	}
+}
+
+// benchmarkProgram keeps every node of the grid busy, passing values down and
+// accumulating them.
+func benchmarkProgram() ([]types.Stream, types.ProgramCode) {
+	streams := make([]types.Stream, 0)
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	for col := range constants.IOPositionsNumber {
+		streams = append(streams, types.Stream{Type: constants.OUTPUT, Name: "OUT", Position: uint8(col)})
+		code.NodesCode[col] = []string{"L: ADD 1", "JGZ W", "JMP L", "W: MOV ACC, ANY", "SWP", "SAV"}
+		code.NodesCode[col+4] = []string{"MOV ANY, ACC", "NEG", "ADD 5", "MOV ACC, DOWN"}
+		code.NodesCode[col+8] = []string{"MOV UP, ACC", "SUB 1", "JLZ N", "MOV ACC, DOWN", "N: NOP"}
+	}
+	return streams, code
+}
+
+var (
+	randomSources = []string{"UP", "RIGHT", "DOWN", "LEFT", "ANY", "LAST", "ACC", "NIL", "1", "-5", "999"}
+	randomDests   = []string{"UP", "RIGHT", "DOWN", "LEFT", "ANY", "LAST", "ACC", "NIL"}
+)
+
+func randomProgram(r *rand.Rand) ([]types.Stream, types.ProgramCode) {
+	streams := make([]types.Stream, 0)
+	for col := range constants.IOPositionsNumber {
+		switch r.Intn(3) {
+		case 0:
+			values := make([]int16, r.Intn(10))
+			for i := range values {
+				values[i] = int16(r.Intn(2*constants.MaxACC+1) - constants.MaxACC)
+			}
+			streams = append(streams, types.Stream{Type: constants.INPUT, Name: "IN", Position: uint8(col), Values: values})
+		case 1:
+			streams = append(streams, types.Stream{Type: constants.OUTPUT, Name: "OUT", Position: uint8(col)})
+		}
+	}
+
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	for i := range code.NodesCode {
+		if r.Intn(4) == 0 {
+			continue
+		}
+		length := 1 + r.Intn(6)
+		lines := make([]string, 0, length)
+		for j := range length {
+			src := randomSources[r.Intn(len(randomSources))]
+			line := ""
+			switch r.Intn(9) {
+			case 0, 1, 2:
+				line = fmt.Sprintf("MOV %s, %s", src, randomDests[r.Intn(len(randomDests))])
+			case 3:
+				line = "ADD " + src
+			case 4:
+				line = "SUB " + src
+			case 5:
+				line = []string{"NEG", "SWP", "SAV", "NOP"}[r.Intn(4)]
+			case 6:
+				line = fmt.Sprintf("%s L%d", []string{"JMP", "JEZ", "JNZ", "JGZ", "JLZ"}[r.Intn(5)], r.Intn(length))
+			case 7:
+				line = fmt.Sprintf("JRO %d", r.Intn(7)-3)
+			case 8:
+				line = "MOV " + src + ", DOWN"
+			}
+			lines = append(lines, fmt.Sprintf("L%d: %s", j, line))
+		}
+		code.NodesCode[i] = lines
+	}
+	return streams, code
+}
+
+// programState describes the state of every node, with nodes referenced by
+// their position in the program.
+func programState(p *emu.Program) []string {
+	nodes := append([]*emu.Node{}, p.Nodes...)
+	for list := p.NodeList; list != nil; list = list.Next {
+		nodes = append(nodes, list.Node)
+	}
+	position := func(n *emu.Node) int {
+		for i, node := range nodes {
+			if node == n {
+				return i
+			}
+		}
+		return -1
+	}
+
+	state := make([]string, 0, len(nodes)+len(p.Outputs))
+	for _, n := range nodes {
+		state = append(state, fmt.Sprintf(
+			"%d %d %d %t %d %d %d",
+			n.CursorPosition, n.ACC, n.BAK, n.Blocked, position(n.OutputPort), n.OutputValue, position(n.Last),
+		))
+	}
+	for _, output := range p.Outputs {
+		state = append(state, fmt.Sprint(output.Values))
+	}
+	return state
+}
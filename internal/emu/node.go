@@ -2,23 +2,26 @@ package emu
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/FranChesK0/tis-100/internal/constants"
 )
 
 type Node struct {
 	Index          uint8
+	Damaged        bool
 	Blocked        bool
 	CursorPosition uint8
 	Instructions   []*Instruction
+	LineNumbers    []int
 	ACC            int16
 	BAK            int16
 	OutputPort     *Node
 	Last           *Node
 	OutputValue    int16
 	Ports          [4]*Node
+	Access         [4]PortAccess
 	Output         *Output
 }
 
@@ -43,67 +46,22 @@ func (n *Node) CreateInstruction(op Operation) *Instruction {
 }
 
 func (n *Node) ParseCode(ic *InputCode) error {
-	for i, line := range ic.Lines {
-		if ind := strings.Index(line, ":"); ind != -1 {
-			label := line[:ind]
-			ic.Labels[label] = uint8(i)
-
-			rem := strings.TrimSpace(line[ind+1:])
-			if len(rem) == 0 {
-				rem = "NOP"
-			}
-			ic.Lines[i] = rem
-		}
-	}
-
-	var err error
-	for _, line := range ic.Lines {
-		if err = n.ParseLine(ic, line); err != nil {
-			return err
-		}
+	if errs := n.parseCode(ic, false); len(errs) > 0 {
+		return errs[0]
 	}
-
+	n.LineNumbers = ic.LineNumbers
 	return nil
 }
 
 func (n *Node) ParseLine(ic *InputCode, line string) error {
-	if len(line) <= 2 {
-		return errors.New("invalid line length")
+	tokens := stripComment(Tokenize(line))
+	if len(tokens) > 0 && tokens[0].Type == LABEL {
+		tokens = tokens[1:]
 	}
-
-	strIns := line[:3]
-	insMap := map[string]Operation{
-		"SUB": SUB,
-		"ADD": ADD,
-		"JEZ": JEZ,
-		"JMP": JMP,
-		"JNZ": JNZ,
-		"JGZ": JGZ,
-		"JLZ": JLZ,
-		"JRO": JRO,
-		"SAV": SAV,
-		"SWP": SWP,
-		"NOP": NOP,
-		"NEG": NEG,
-		"OUT": OUT,
-	}
-	var err error
-	switch strIns {
-	case "MOV":
-		if err = n.parseMov(line); err != nil {
-			return err
-		}
-	case "SUB", "ADD", "JEZ", "JMP", "JNZ", "JGZ", "JLZ", "JRO":
-		if err = n.parseOneArg(ic, line, insMap[strIns]); err != nil {
-			return err
-		}
-	case "SAV", "SWP", "NOP", "NEG", "OUT":
-		n.CreateInstruction(insMap[strIns])
-	default:
-		return errors.New("invalid instruction")
+	if len(tokens) == 0 {
+		return errors.New("invalid line length")
 	}
-
-	return nil
+	return n.parseTokens(ic, tokens)
 }
 
 func (n *Node) Read(locType LocationType, loc Location) (ReadResult, error) {
@@ -170,6 +128,37 @@ func (n *Node) Write(dir LocationDirection, value int16) (bool, error) {
 	return false, nil
 }
 
+// Mode tells what the node is busy with, as shown by the game: running,
+// waiting to read, waiting for its value to be read or idle without code.
+func (n *Node) Mode() NodeMode {
+	if len(n.Instructions) == 0 {
+		return IDLE
+	}
+	if !n.Blocked {
+		return RUN
+	}
+	if n.OutputPort != nil {
+		return WRTE
+	}
+
+	pos := int(n.CursorPosition)
+	if pos >= len(n.Instructions) {
+		pos = 0
+	}
+	ins := n.Instructions[pos]
+	switch ins.Src.Direction {
+	case UP, RIGHT, DOWN, LEFT, ANY, LAST:
+		if ins.SrcType == ADDRESS {
+			return READ
+		}
+	}
+	return WRTE
+}
+
+func (m NodeMode) String() string {
+	return [...]string{"IDLE", "RUN", "READ", "WRTE"}[m]
+}
+
 func (n *Node) MoveCursor() {
 	n.CursorPosition++
 }
@@ -222,29 +211,29 @@ func (n *Node) Tick() error {
 		n.ACC -= read.Value
 		n.normalizeACC()
 	case JMP:
-		n.setCursorPosition(ins.Src.Number)
+		n.jump(ins.Src.Number)
 		return nil
 	case JRO:
-		n.setCursorPosition(int16(n.CursorPosition) + ins.Src.Number)
+		n.jump(int16(n.CursorPosition) + ins.Src.Number)
 		return nil
 	case JEZ:
 		if n.ACC == 0 {
-			n.setCursorPosition(ins.Src.Number)
+			n.jump(ins.Src.Number)
 			return nil
 		}
 	case JGZ:
 		if n.ACC > 0 {
-			n.setCursorPosition(ins.Src.Number)
+			n.jump(ins.Src.Number)
 			return nil
 		}
 	case JLZ:
 		if n.ACC < 0 {
-			n.setCursorPosition(ins.Src.Number)
+			n.jump(ins.Src.Number)
 			return nil
 		}
 	case JNZ:
 		if n.ACC != 0 {
-			n.setCursorPosition(ins.Src.Number)
+			n.jump(ins.Src.Number)
 			return nil
 		}
 	case SWP:
@@ -261,7 +250,7 @@ func (n *Node) Tick() error {
 			n.Output.AddValue(n.ACC)
 		}
 	default:
-		return errors.New("unknown operation")
+		return fmt.Errorf("unknown operation %s", ins.Operation)
 	}
 
 	n.Blocked = false
@@ -269,54 +258,129 @@ func (n *Node) Tick() error {
 	return nil
 }
 
-func (n *Node) parseMov(line string) error {
-	if len(line) <= 3 {
-		return errors.New("wrong mov instruction format")
+func (n *Node) parseCode(ic *InputCode, collect bool) []*AssemblyError {
+	errs := make([]*AssemblyError, 0)
+	labelErrs := make(map[int]string)
+	lines := make([][]Token, len(ic.Lines))
+	for i, line := range ic.Lines {
+		tokens := stripComment(Tokenize(line))
+		if len(tokens) > 0 && tokens[0].Type == LABEL {
+			label := tokens[0].Value
+			if label == "" {
+				labelErrs[i] = "empty label"
+			} else if _, ok := ic.Labels[label]; ok {
+				labelErrs[i] = "duplicate label " + label
+			}
+			ic.Labels[label] = uint8(len(ic.LineNumbers))
+
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				tokens = []Token{{Type: OPCODE, Value: "NOP"}}
+			}
+		}
+		if len(tokens) > 0 {
+			ic.LineNumbers = append(ic.LineNumbers, i)
+		}
+		lines[i] = tokens
 	}
-	rem := line[4:]
-	var tokens []string
-	if strings.Contains(rem, ", ") {
-		tokens = strings.Split(rem, ", ")
-	} else if strings.Contains(rem, ",") {
-		tokens = strings.Split(rem, ",")
-	} else {
-		tokens = strings.Split(rem, " ")
+
+	for i, tokens := range lines {
+		if len(tokens) == 0 {
+			continue
+		}
+
+		msg, ok := labelErrs[i]
+		if !ok {
+			if err := n.parseTokens(ic, tokens); err != nil {
+				msg, ok = err.Error(), true
+			}
+		}
+		if ok {
+			errs = append(errs, &AssemblyError{Node: n.Index, Line: i, Message: msg})
+			if !collect {
+				return errs
+			}
+		}
+	}
+
+	return errs
+}
+
+func (n *Node) parseTokens(ic *InputCode, tokens []Token) error {
+	if tokens[0].Type != OPCODE {
+		return fmt.Errorf("invalid instruction %s", tokens[0].Value)
+	}
+
+	op := opcodes[tokens[0].Value]
+	operands := make([]Token, 0, 2)
+	for _, tok := range tokens[1:] {
+		if tok.Type != COMMA {
+			operands = append(operands, tok)
+		}
 	}
-	if len(tokens) != 2 {
+
+	switch op {
+	case MOV:
+		return n.parseMov(operands)
+	case SUB, ADD, JEZ, JMP, JNZ, JGZ, JLZ, JRO:
+		return n.parseOneArg(ic, operands, op)
+	default:
+		if len(operands) != 0 {
+			return fmt.Errorf("%s takes no operands", tokens[0].Value)
+		}
+		n.CreateInstruction(op)
+	}
+
+	return nil
+}
+
+func (n *Node) parseMov(operands []Token) error {
+	if len(operands) != 2 {
 		return errors.New("wrong mov instruction format")
 	}
 
-	ins := n.CreateInstruction(MOV)
-	var err error
-	if err = parseLocation(tokens[0], &ins.SrcType, &ins.Src); err != nil {
+	srcType, src, err := parseLocation(operands[0])
+	if err != nil {
 		return err
 	}
-	if err = parseLocation(tokens[1], &ins.DestType, &ins.Dest); err != nil {
+	destType, dest, err := parseLocation(operands[1])
+	if err != nil {
 		return err
 	}
+	if destType == NUMBER {
+		return fmt.Errorf("unable to write to %s", operands[1].Value)
+	}
 
+	ins := n.CreateInstruction(MOV)
+	ins.SrcType, ins.Src = srcType, src
+	ins.DestType, ins.Dest = destType, dest
 	return nil
 }
 
-func (n *Node) parseOneArg(ic *InputCode, line string, op Operation) error {
-	if len(line) <= 3 {
+func (n *Node) parseOneArg(ic *InputCode, operands []Token, op Operation) error {
+	if len(operands) != 1 {
 		return errors.New("wrong one arg instruction format")
 	}
-	rem := line[4:]
-	ins := n.CreateInstruction(op)
 
 	switch op {
 	case JEZ, JMP, JNZ, JGZ, JLZ:
-		for label, pos := range ic.Labels {
-			if rem == label {
-				ins.SrcType = NUMBER
-				ins.Src.Number = int16(pos)
-			}
+		if operands[0].Type != LABEL {
+			return fmt.Errorf("invalid label %s", operands[0].Value)
+		}
+		pos, ok := ic.Labels[operands[0].Value]
+		if !ok {
+			return fmt.Errorf("undefined label %s", operands[0].Value)
 		}
+		ins := n.CreateInstruction(op)
+		ins.SrcType = NUMBER
+		ins.Src.Number = int16(pos)
 	default:
-		if err := parseLocation(rem, &ins.SrcType, &ins.Src); err != nil {
+		srcType, src, err := parseLocation(operands[0])
+		if err != nil {
 			return err
 		}
+		ins := n.CreateInstruction(op)
+		ins.SrcType, ins.Src = srcType, src
 	}
 
 	return nil
@@ -328,13 +392,16 @@ func (n *Node) getInputPort(dir LocationDirection) *Node {
 		dirs := []LocationDirection{LEFT, RIGHT, UP, DOWN}
 		for _, d := range dirs {
 			port := n.Ports[d]
-			if port != nil && port.OutputPort == n {
+			if port != nil && port.OutputPort == n && n.Access[d] != WRITEONLY {
 				return port
 			}
 		}
 	case LAST:
 		return n.Last
 	default:
+		if n.Access[dir] == WRITEONLY {
+			return nil
+		}
 		return n.Ports[dir]
 	}
 	return nil
@@ -346,27 +413,46 @@ func (n *Node) getOutputPort(dir LocationDirection) *Node {
 		dirs := []LocationDirection{UP, LEFT, RIGHT, DOWN}
 		for _, d := range dirs {
 			port := n.Ports[d]
-			if port != nil {
-				ins := port.Instructions[port.CursorPosition]
-				if ins.Operation == MOV && ins.SrcType == ADDRESS &&
-					(ins.Src.Direction == ANY || port.Ports[ins.Src.Direction] == n) {
-					return port
-				}
+			if port != nil && n.Access[d] != READONLY && port.readsFrom(n) {
+				return port
 			}
 		}
 	case LAST:
 		return n.Last
 	default:
+		if n.Access[dir] == READONLY {
+			return nil
+		}
 		return n.Ports[dir]
 	}
 	return nil
 }
 
-func (n *Node) setCursorPosition(pos int16) {
+// readsFrom reports whether the next instruction of n is a MOV reading from
+// the given neighbour.
+func (n *Node) readsFrom(neighbour *Node) bool {
+	if len(n.Instructions) == 0 {
+		return false
+	}
+	pos := n.CursorPosition
+	if pos >= uint8(len(n.Instructions)) {
+		pos = 0
+	}
+	ins := n.Instructions[pos]
+	if ins.Operation != MOV || ins.SrcType != ADDRESS {
+		return false
+	}
+	return ins.Src.Direction == ANY || (ins.Src.Direction <= LEFT && n.Ports[ins.Src.Direction] == neighbour)
+}
+
+// jump moves the cursor to pos. The node is not blocked by a jump, it just
+// does not move the cursor forward.
+func (n *Node) jump(pos int16) {
 	if pos >= int16(len(n.Instructions)) || pos < 0 {
 		pos = 0
 	}
 	n.CursorPosition = uint8(pos)
+	n.Blocked = false
 }
 
 func (n *Node) normalizeACC() {
@@ -378,38 +464,37 @@ func (n *Node) normalizeACC() {
 	}
 }
 
-func parseLocation(strLoc string, locType *LocationType, loc *Location) error {
-	if strLoc == "" {
-		return errors.New("no source was found")
-	}
-
-	*locType = ADDRESS
-	switch strLoc {
-	case "UP":
-		loc.Direction = UP
-	case "DOWN":
-		loc.Direction = DOWN
-	case "LEFT":
-		loc.Direction = LEFT
-	case "RIGHT":
-		loc.Direction = RIGHT
-	case "ACC":
-		loc.Direction = ACC
-	case "NIL":
-		loc.Direction = NIL
-	case "ANY":
-		loc.Direction = ANY
-	case "LAST":
-		loc.Direction = LAST
-	default:
-		num, err := strconv.Atoi(strLoc)
+func parseLocation(tok Token) (LocationType, Location, error) {
+	switch tok.Type {
+	case LITERAL:
+		num, err := strconv.Atoi(tok.Value)
 		if err != nil {
-			return err
+			return NUMBER, Location{}, err
 		}
-
-		*locType = NUMBER
-		loc.Number = int16(num)
+		if num < constants.MinACC || num > constants.MaxACC {
+			return NUMBER, Location{}, fmt.Errorf(
+				"value %d is not in range from %d to %d",
+				num,
+				constants.MinACC,
+				constants.MaxACC,
+			)
+		}
+		return NUMBER, Location{Number: int16(num)}, nil
+	case REGISTER:
+		if tok.Value == "BAK" {
+			return ADDRESS, Location{}, errors.New("BAK is not addressable")
+		}
+		return ADDRESS, Location{Direction: registers[tok.Value]}, nil
+	case PORT:
+		return ADDRESS, Location{Direction: ports[tok.Value]}, nil
+	default:
+		return ADDRESS, Location{}, fmt.Errorf("invalid operand %s", tok.Value)
 	}
+}
 
-	return nil
+func stripComment(tokens []Token) []Token {
+	if len(tokens) > 0 && tokens[len(tokens)-1].Type == COMMENT {
+		return tokens[:len(tokens)-1]
+	}
+	return tokens
 }
@@ -2,6 +2,8 @@ package emu
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"strings"
 
 	"github.com/FranChesK0/tis-100/internal/constants"
@@ -9,36 +11,48 @@ import (
 )
 
 type Program struct {
+	Width       int
+	Height      int
 	Nodes       []*Node
 	NodeList    *NodeList
 	ActiveNodes *NodeList
+	Inputs      []*Node
 	Outputs     []*Output
 }
 
 func NewProgram() *Program {
-	nodes := make([]*Node, 0, constants.NodesNumber)
+	return NewGrid(constants.DefaultGridWidth, constants.DefaultGridHeight)
+}
+
+// NewGrid creates a program of width x height nodes numbered row by row, each
+// node connected to its neighbours.
+func NewGrid(width int, height int) *Program {
+	nodes := make([]*Node, 0, width*height)
 	var n *Node
-	for i := range constants.NodesNumber {
+	for i := range width * height {
 		n = NewNode()
 		n.Index = uint8(i)
 		nodes = append(nodes, n)
 	}
 	p := &Program{
+		Width:   width,
+		Height:  height,
 		Nodes:   nodes,
 		Outputs: make([]*Output, 0),
 	}
 
 	for i := range p.Nodes {
-		if i != 8 && i != 9 && i != 10 && i != 11 {
-			p.Nodes[i].Ports[DOWN] = p.Nodes[i+4]
+		row, col := i/width, i%width
+		if row < height-1 {
+			p.Nodes[i].Ports[DOWN] = p.Nodes[i+width]
 		}
-		if i != 0 && i != 1 && i != 2 && i != 3 {
-			p.Nodes[i].Ports[UP] = p.Nodes[i-4]
+		if row > 0 {
+			p.Nodes[i].Ports[UP] = p.Nodes[i-width]
 		}
-		if i != 3 && i != 7 && i != 11 {
+		if col < width-1 {
 			p.Nodes[i].Ports[RIGHT] = p.Nodes[i+1]
 		}
-		if i != 0 && i != 4 && i != 8 {
+		if col > 0 {
 			p.Nodes[i].Ports[LEFT] = p.Nodes[i-1]
 		}
 	}
@@ -46,6 +60,56 @@ func NewProgram() *Program {
 	return p
 }
 
+var portNames = [...]string{"UP", "RIGHT", "DOWN", "LEFT"}
+
+// Connect replaces the wiring of the grid with the given connections. Every
+// port is used by one connection at most, and two nodes are connected once at
+// most, as reading could not tell which of the ports a value came from.
+func (p *Program) Connect(connections []types.Connection) error {
+	for _, n := range p.Nodes {
+		n.Ports = [4]*Node{}
+		n.Access = [4]PortAccess{}
+	}
+
+	linked := make(map[[2]int]bool)
+	for i, c := range connections {
+		for _, end := range []struct {
+			node int
+			port types.Port
+		}{{c.From, c.FromPort}, {c.To, c.ToPort}} {
+			if end.node < 0 || end.node >= len(p.Nodes) {
+				return fmt.Errorf("connection %d: node %d is out of grid", i+1, end.node)
+			}
+			if end.port >= constants.PortsNumber {
+				return fmt.Errorf("connection %d: unknown port %d", i+1, end.port)
+			}
+		}
+		if c.From == c.To {
+			return fmt.Errorf("connection %d: node %d is connected to itself", i+1, c.From)
+		}
+		from, to := p.Nodes[c.From], p.Nodes[c.To]
+		if from.Ports[c.FromPort] != nil {
+			return fmt.Errorf("connection %d: port %s of node %d is already connected", i+1, portNames[c.FromPort], c.From)
+		}
+		if to.Ports[c.ToPort] != nil {
+			return fmt.Errorf("connection %d: port %s of node %d is already connected", i+1, portNames[c.ToPort], c.To)
+		}
+		pair := [2]int{min(c.From, c.To), max(c.From, c.To)}
+		if linked[pair] {
+			return fmt.Errorf("connection %d: nodes %d and %d are already connected", i+1, c.From, c.To)
+		}
+		linked[pair] = true
+
+		from.Ports[c.FromPort] = to
+		to.Ports[c.ToPort] = from
+		if c.OneWay {
+			from.Access[c.FromPort] = WRITEONLY
+			to.Access[c.ToPort] = READONLY
+		}
+	}
+	return nil
+}
+
 func (p *Program) Tick() (bool, error) {
 	allBlocked := true
 	var err error
@@ -60,10 +124,17 @@ func (p *Program) Tick() (bool, error) {
 
 func (p *Program) LoadStreams(streams []types.Stream) error {
 	for _, stream := range streams {
+		if int(stream.Position) >= p.Width {
+			return errors.New("stream position is out of grid")
+		}
+		if p.StreamPort(stream) != nil {
+			return fmt.Errorf("port of stream %s is already connected", stream.Name)
+		}
 		switch stream.Type {
 		case constants.INPUT:
 			n := p.createInputNode(stream)
 			p.ActiveNodes = Append(p.ActiveNodes, n)
+			p.Inputs = append(p.Inputs, n)
 		case constants.OUTPUT:
 			n := p.createOutputNode(stream)
 			p.ActiveNodes = Append(p.ActiveNodes, n)
@@ -75,12 +146,12 @@ func (p *Program) LoadStreams(streams []types.Stream) error {
 }
 
 func (p *Program) LoadCode(code types.ProgramCode) error {
-	if len(code.NodesCode) != constants.NodesNumber {
+	if len(code.NodesCode) > len(p.Nodes) {
 		return errors.New("wrong nodes number")
 	}
 
 	allInput := make([]InputCode, 0)
-	for range constants.NodesNumber {
+	for range p.Nodes {
 		allInput = append(allInput, NewInputCode())
 	}
 
@@ -95,6 +166,9 @@ func (p *Program) LoadCode(code types.ProgramCode) error {
 		if err := n.ParseCode(&allInput[n.Index]); err != nil {
 			return err
 		}
+		if n.Damaged && len(n.Instructions) > 0 {
+			return fmt.Errorf("node %d is damaged and can not run code", n.Index)
+		}
 		if len(n.Instructions) > 0 {
 			p.ActiveNodes = Append(p.ActiveNodes, n)
 		}
@@ -103,6 +177,43 @@ func (p *Program) LoadCode(code types.ProgramCode) error {
 	return nil
 }
 
+// PushInput appends a value to the input stream with the given index while
+// the program is running. Values already sent are dropped from the input node
+// to keep its cursor in range.
+func (p *Program) PushInput(index int, value int16) error {
+	if index < 0 || index >= len(p.Inputs) {
+		return errors.New("unknown input stream")
+	}
+	n := p.Inputs[index]
+	if len(n.Instructions) > math.MaxUint8 {
+		return errors.New("input stream is full")
+	}
+
+	consumed := n.CursorPosition
+	n.Instructions = n.Instructions[consumed:]
+	n.CursorPosition = 0
+
+	last := n.Instructions[len(n.Instructions)-1]
+	ins := &Instruction{
+		Operation: MOV,
+		SrcType:   NUMBER,
+		Src:       Location{Number: value},
+		DestType:  ADDRESS,
+		Dest:      Location{Direction: DOWN},
+	}
+	n.Instructions = append(n.Instructions[:len(n.Instructions)-1], ins, last)
+	return nil
+}
+
+// StreamPort returns what the node of the stream is connected to on the side
+// of the stream.
+func (p *Program) StreamPort(stream types.Stream) *Node {
+	if stream.Type == constants.INPUT {
+		return p.Nodes[stream.Position].Ports[UP]
+	}
+	return p.Nodes[int(stream.Position)+(p.Height-1)*p.Width].Ports[DOWN]
+}
+
 func (p *Program) createNode() *Node {
 	n := NewNode()
 	p.NodeList = Append(p.NodeList, n)
@@ -113,6 +224,7 @@ func (p *Program) createInputNode(stream types.Stream) *Node {
 	inputNode := p.createNode()
 	inputNode.Index = stream.Position
 	belowNode := p.Nodes[stream.Position]
+	belowNode.Access[UP] = READWRITE
 
 	inputNode.Ports[DOWN] = belowNode
 	belowNode.Ports[UP] = inputNode
@@ -134,8 +246,10 @@ func (p *Program) createInputNode(stream types.Stream) *Node {
 
 func (p *Program) createOutputNode(stream types.Stream) *Node {
 	outputNode := p.createNode()
-	outputNode.Index = stream.Position + 8
-	aboveNode := p.Nodes[stream.Position+8]
+	index := int(stream.Position) + (p.Height-1)*p.Width
+	outputNode.Index = uint8(index)
+	aboveNode := p.Nodes[index]
+	aboveNode.Access[DOWN] = READWRITE
 
 	outputNode.Ports[UP] = aboveNode
 	aboveNode.Ports[DOWN] = outputNode
@@ -0,0 +1,160 @@
+package emu_test
+
+import (
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// NewGrid
+func TestNewGridWiring(t *testing.T) {
+	p := emu.NewGrid(5, 2)
+	if len(p.Nodes) != 10 {
+		t.Fatalf("wrong nodes number. expected: 10, got: %d", len(p.Nodes))
+	}
+
+	cases := []struct {
+		node      int
+		direction emu.LocationDirection
+		neighbour int
+	}{
+		{0, emu.RIGHT, 1},
+		{0, emu.DOWN, 5},
+		{4, emu.DOWN, 9},
+		{4, emu.RIGHT, -1},
+		{5, emu.LEFT, -1},
+		{5, emu.UP, 0},
+		{9, emu.DOWN, -1},
+	}
+	for _, c := range cases {
+		port := p.Nodes[c.node].Ports[c.direction]
+		if c.neighbour < 0 {
+			if port != nil {
+				t.Errorf("node %d is not expected to have a neighbour at %s", c.node, c.direction)
+			}
+			continue
+		}
+		if port != p.Nodes[c.neighbour] {
+			t.Errorf("wrong neighbour of node %d at %s. expected: %d", c.node, c.direction, c.neighbour)
+		}
+	}
+}
+
+// Connect
+func TestConnectWithTorus(t *testing.T) {
+	p := emu.NewGrid(3, 1)
+	err := p.Connect([]types.Connection{
+		{From: 0, FromPort: constants.RIGHT, To: 1, ToPort: constants.LEFT},
+		{From: 1, FromPort: constants.RIGHT, To: 2, ToPort: constants.LEFT},
+		{From: 2, FromPort: constants.RIGHT, To: 0, ToPort: constants.LEFT, OneWay: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.Nodes[0].Ports[emu.RIGHT] != p.Nodes[1] || p.Nodes[1].Ports[emu.LEFT] != p.Nodes[0] {
+		t.Error("nodes are not connected both ways")
+	}
+	if p.Nodes[2].Ports[emu.RIGHT] != p.Nodes[0] || p.Nodes[0].Ports[emu.LEFT] != p.Nodes[2] {
+		t.Error("edge nodes are not connected")
+	}
+	if p.Nodes[2].Access[emu.RIGHT] != emu.WRITEONLY || p.Nodes[0].Access[emu.LEFT] != emu.READONLY {
+		t.Error("one way connection has wrong port access")
+	}
+}
+
+func TestConnectWithWrongConnections(t *testing.T) {
+	cases := []struct {
+		connections []types.Connection
+		expectedErr string
+	}{
+		{
+			[]types.Connection{{From: 0, To: 4}},
+			"connection 1: node 4 is out of grid",
+		},
+		{
+			[]types.Connection{{From: 1, FromPort: constants.UP, To: 1, ToPort: constants.DOWN}},
+			"connection 1: node 1 is connected to itself",
+		},
+		{
+			[]types.Connection{
+				{From: 0, FromPort: constants.RIGHT, To: 1, ToPort: constants.LEFT},
+				{From: 0, FromPort: constants.RIGHT, To: 2, ToPort: constants.LEFT},
+			},
+			"connection 2: port RIGHT of node 0 is already connected",
+		},
+		{
+			[]types.Connection{
+				{From: 0, FromPort: constants.RIGHT, To: 1, ToPort: constants.LEFT},
+				{From: 1, FromPort: constants.RIGHT, To: 0, ToPort: constants.LEFT},
+			},
+			"connection 2: nodes 1 and 0 are already connected",
+		},
+	}
+	for _, c := range cases {
+		err := emu.NewGrid(2, 2).Connect(c.connections)
+		if err == nil {
+			t.Errorf("expected to occure error for %+v", c.connections)
+			continue
+		}
+		if err.Error() != c.expectedErr {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+// LoadStreams
+func TestLoadStreamsOutOfGrid(t *testing.T) {
+	p := emu.NewGrid(2, 2)
+	err := p.LoadStreams([]types.Stream{{Type: constants.INPUT, Name: "IN", Position: 2}})
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "stream position is out of grid"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+// Tick
+func TestTickWithWriteToAny(t *testing.T) {
+	// The neighbour on the right has no code or reads from no port.
+	neighbours := [][]string{nil, {"MOV ACC, ACC"}}
+	for _, neighbour := range neighbours {
+		code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+		code.NodesCode[0] = []string{"MOV 1, ANY"}
+		code.NodesCode[1] = neighbour
+		p := emu.NewProgram()
+		if err := p.LoadCode(code); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for range 3 {
+			if _, err := p.Tick(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		if !p.Nodes[0].Blocked {
+			t.Errorf("node writing to no reader is not blocked, neighbour code: %q", neighbour)
+		}
+	}
+}
+
+func TestTickWithJump(t *testing.T) {
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	code.NodesCode[0] = []string{"L: JMP L"}
+	p := emu.NewProgram()
+	if err := p.LoadCode(code); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	blocked, err := p.Tick()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if blocked || p.Nodes[0].Blocked {
+		t.Error("node taking a jump is blocked")
+	}
+}
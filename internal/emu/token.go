@@ -0,0 +1,143 @@
+package emu
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type TokenType uint8
+
+type Token struct {
+	Type  TokenType
+	Value string
+	Start int
+	End   int
+}
+
+const (
+	OPCODE TokenType = iota
+	REGISTER
+	PORT
+	LABEL
+	LITERAL
+	COMMENT
+	COMMA
+	INVALID
+)
+
+var opcodes = map[string]Operation{
+	"MOV": MOV,
+	"SAV": SAV,
+	"SWP": SWP,
+	"SUB": SUB,
+	"ADD": ADD,
+	"NOP": NOP,
+	"NEG": NEG,
+	"JEZ": JEZ,
+	"JMP": JMP,
+	"JNZ": JNZ,
+	"JGZ": JGZ,
+	"JLZ": JLZ,
+	"JRO": JRO,
+	"OUT": OUT,
+}
+
+var registers = map[string]LocationDirection{
+	"ACC": ACC,
+	"NIL": NIL,
+	"BAK": BAK,
+}
+
+var ports = map[string]LocationDirection{
+	"UP":    UP,
+	"RIGHT": RIGHT,
+	"DOWN":  DOWN,
+	"LEFT":  LEFT,
+	"ANY":   ANY,
+	"LAST":  LAST,
+}
+
+// Tokenize splits a single line of assembly into tokens. Start and End are
+// byte offsets into the original line, so the result can be used both to
+// assemble the line and to highlight it as typed.
+func Tokenize(line string) []Token {
+	tokens := make([]Token, 0)
+
+	pos := 0
+	if ind := strings.IndexAny(line, ":#"); ind != -1 && line[ind] == ':' {
+		label := strings.TrimSpace(line[:ind])
+		start := strings.Index(line, label)
+		if label == "" {
+			start = ind
+		}
+		tokens = append(tokens, Token{
+			Type:  LABEL,
+			Value: strings.ToUpper(label),
+			Start: start,
+			End:   ind + 1,
+		})
+		pos = ind + 1
+	}
+
+	operation, hasOperation := Operation(0), false
+	for pos < len(line) {
+		c := rune(line[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '#':
+			tokens = append(tokens, Token{
+				Type:  COMMENT,
+				Value: line[pos:],
+				Start: pos,
+				End:   len(line),
+			})
+			pos = len(line)
+		case c == ',':
+			tokens = append(tokens, Token{Type: COMMA, Value: ",", Start: pos, End: pos + 1})
+			pos++
+		default:
+			end := pos
+			for end < len(line) && !unicode.IsSpace(rune(line[end])) &&
+				line[end] != ',' && line[end] != '#' {
+				end++
+			}
+			word := strings.ToUpper(line[pos:end])
+			tok := Token{Type: INVALID, Value: word, Start: pos, End: end}
+			if !hasOperation {
+				if op, ok := opcodes[word]; ok {
+					tok.Type = OPCODE
+					operation, hasOperation = op, true
+				}
+			} else {
+				tok.Type = classifyOperand(operation, word)
+			}
+			if tok.Type == INVALID && !hasOperation {
+				hasOperation = true
+				operation = NOP
+			}
+			tokens = append(tokens, tok)
+			pos = end
+		}
+	}
+
+	return tokens
+}
+
+func classifyOperand(op Operation, word string) TokenType {
+	if _, ok := registers[word]; ok {
+		return REGISTER
+	}
+	if _, ok := ports[word]; ok {
+		return PORT
+	}
+	if _, err := strconv.Atoi(word); err == nil {
+		return LITERAL
+	}
+	switch op {
+	case JEZ, JMP, JNZ, JGZ, JLZ:
+		return LABEL
+	}
+	return INVALID
+}
@@ -0,0 +1,90 @@
+package emu_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/emu"
+)
+
+/* TESTS */
+
+// Tokenize
+func TestTokenizeWithAllTokenTypes(t *testing.T) {
+	tokens := emu.Tokenize("start: mov up, acc # copy")
+
+	expectedTypes := []emu.TokenType{
+		emu.LABEL,
+		emu.OPCODE,
+		emu.PORT,
+		emu.COMMA,
+		emu.REGISTER,
+		emu.COMMENT,
+	}
+	types := make([]emu.TokenType, 0, len(tokens))
+	for _, tok := range tokens {
+		types = append(types, tok.Type)
+	}
+	if !reflect.DeepEqual(types, expectedTypes) {
+		t.Errorf("wrong token types. expected: %v, got: %v", expectedTypes, types)
+	}
+	if tokens[1].Value != "MOV" || tokens[1].Start != 7 || tokens[1].End != 10 {
+		t.Errorf("wrong opcode token: %+v", tokens[1])
+	}
+}
+
+func TestTokenizeWithLabelReference(t *testing.T) {
+	tokens := emu.Tokenize("JEZ LOOP")
+	if len(tokens) != 2 || tokens[1].Type != emu.LABEL {
+		t.Errorf("expected label reference, got: %+v", tokens)
+	}
+
+	tokens = emu.Tokenize("ADD LOOP")
+	if len(tokens) != 2 || tokens[1].Type != emu.INVALID {
+		t.Errorf("expected invalid operand, got: %+v", tokens)
+	}
+}
+
+// CheckCode
+func TestCheckCodeWithCorrectCode(t *testing.T) {
+	errs := emu.CheckCode([]string{
+		"# comment only",
+		"L: MOV UP ACC",
+		"",
+		"JGZ L",
+		"MOV ACC, DOWN",
+	})
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestCheckCodeWithWrongCode(t *testing.T) {
+	errs := emu.CheckCode([]string{
+		"MOV UP",
+		"ADD BAK",
+		"JMP NOWHERE",
+		"MOV 1, 2",
+		"FOO",
+		"SWP ACC",
+		"SUB 1000",
+	})
+
+	expected := []string{
+		"node 1, line 1: wrong mov instruction format",
+		"node 1, line 2: BAK is not addressable",
+		"node 1, line 3: undefined label NOWHERE",
+		"node 1, line 4: unable to write to 2",
+		"node 1, line 5: invalid instruction FOO",
+		"node 1, line 6: SWP takes no operands",
+		"node 1, line 7: value 1000 is not in range from -999 to 999",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("wrong errors number. expected: %d, got: %d", len(expected), len(errs))
+	}
+	for i, err := range errs {
+		if err.Error() != expected[i] {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", expected[i], err.Error())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package format
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// Line rewrites a line of code in the canonical style: upper case labels,
+// mnemonics and operands, operands separated by ", " and a single space
+// before a comment, which is kept as typed. Labels are always followed by the
+// instruction of their line. A label alone on a line assembles into a NOP, so
+// it gets an explicit one, unless the line would no longer fit in a node.
+// Lines with invalid tokens are only trimmed.
+func Line(line string) string {
+	tokens := emu.Tokenize(line)
+	parts := make([]string, 0, 3)
+	operands := make([]string, 0, 2)
+	label, opcode := false, false
+	for i, tok := range tokens {
+		switch {
+		case tok.Type == emu.INVALID:
+			return strings.TrimSpace(line)
+		case tok.Type == emu.LABEL && i == 0:
+			parts = append(parts, tok.Value+":")
+			label = true
+		case tok.Type == emu.OPCODE:
+			parts = append(parts, tok.Value)
+			opcode = true
+		case tok.Type == emu.COMMENT:
+			if len(operands) > 0 {
+				parts[len(parts)-1] += " " + strings.Join(operands, ", ")
+				operands = operands[:0]
+			}
+			parts = append(parts, strings.TrimSpace(tok.Value))
+		case tok.Type != emu.COMMA:
+			operands = append(operands, tok.Value)
+		}
+	}
+	if len(operands) > 0 {
+		parts[len(parts)-1] += " " + strings.Join(operands, ", ")
+	}
+	formatted := strings.Join(parts, " ")
+	if label && !opcode {
+		parts = append(parts[:1], append([]string{emu.NOP.String()}, parts[1:]...)...)
+		if withNOP := strings.Join(parts, " "); len(withNOP) <= constants.MaxLineLength {
+			return withNOP
+		}
+	}
+	return formatted
+}
+
+// Code formats every line of code.
+func Code(code *types.ProgramCode) *types.ProgramCode {
+	nodesCode := make([][]string, 0, len(code.NodesCode))
+	for _, lines := range code.NodesCode {
+		formatted := make([]string, 0, len(lines))
+		for _, line := range lines {
+			formatted = append(formatted, Line(line))
+		}
+		nodesCode = append(nodesCode, formatted)
+	}
+	return &types.ProgramCode{Title: code.Title, NodesCode: nodesCode}
+}
+
+// Source formats the content of a solution file. Blank lines are dropped and
+// nodes are numbered from 1 in the order they come in.
+func Source(src []byte) ([]byte, error) {
+	code, err := parser.ParseCode("", bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := parser.WriteCode(&buf, Code(code)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
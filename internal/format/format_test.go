@@ -0,0 +1,59 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/format"
+)
+
+/* TESTS */
+
+// Line
+func TestLine(t *testing.T) {
+	lines := map[string]string{
+		"mov up acc":                 "MOV UP, ACC",
+		"  MOV   LEFT ,DOWN  ":       "MOV LEFT, DOWN",
+		"start:add 1":                "START: ADD 1",
+		"loop :":                     "LOOP: NOP",
+		"verylonglabel17:":           "VERYLONGLABEL17:",
+		"jgz loop#Keep Case  ":       "JGZ LOOP #Keep Case",
+		"#   a comment":              "#   a comment",
+		"l: # note":                  "L: NOP # note",
+		"swp":                        "SWP",
+		"mov -5, acc":                "MOV -5, ACC",
+		"  foo bar, baz  # invalid ": "foo bar, baz  # invalid",
+	}
+	for line, expected := range lines {
+		if got := format.Line(line); got != expected {
+			t.Errorf("wrong line for %q. expected: %q, got: %q", line, expected, got)
+		}
+	}
+}
+
+// Source
+func TestSource(t *testing.T) {
+	src := "@1\nmov up acc # read\n\n  add 1\n@5\n\nl: jmp l\n@3\nloop:\nneg\njmp loop\n"
+	expected := "@1\nMOV UP, ACC # read\nADD 1\n\n@2\nL: JMP L\n\n@3\nLOOP: NOP\nNEG\nJMP LOOP\n\n"
+
+	got, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(got) != expected {
+		t.Errorf("wrong source. expected:\n%q\ngot:\n%q", expected, string(got))
+	}
+
+	again, err := format.Source(got)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(again) != expected {
+		t.Errorf("expected formatting to be idempotent, got:\n%q", string(again))
+	}
+}
+
+func TestSourceWithWrongInput(t *testing.T) {
+	if _, err := format.Source([]byte("MOV UP, ACC\n@1\n")); err == nil {
+		t.Error("expected to occure error, got nil")
+	}
+}
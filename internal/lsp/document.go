@@ -0,0 +1,270 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+)
+
+// source names the server in diagnostics.
+const source = "tis-100"
+
+// document is a solution file split into nodes. Lines before the first node
+// header belong to no node.
+type document struct {
+	lines []string
+	nodes []node
+}
+
+// node is the code of a node, from the line after its header up to the next
+// header.
+type node struct {
+	header int
+	start  int
+	end    int
+}
+
+func parseDocument(text string) *document {
+	doc := &document{lines: strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")}
+	for i, line := range doc.lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "@") {
+			if len(doc.nodes) > 0 {
+				doc.nodes[len(doc.nodes)-1].end = i
+			}
+			doc.nodes = append(doc.nodes, node{header: i, start: i + 1, end: len(doc.lines)})
+		}
+	}
+	return doc
+}
+
+// nodeAt returns the node the line belongs to.
+func (d *document) nodeAt(line int) (node, bool) {
+	for _, n := range d.nodes {
+		if line >= n.start && line < n.end {
+			return n, true
+		}
+	}
+	return node{}, false
+}
+
+func (d *document) lineSpan(line int) span {
+	text := d.lines[line]
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	end := len(strings.TrimRight(text, " \t"))
+	return span{Start: position{line, start}, End: position{line, max(start, end)}}
+}
+
+func (d *document) diagnostics() []diagnostic {
+	diags := make([]diagnostic, 0)
+	add := func(line int, severity int, format string, args ...any) {
+		diags = append(diags, diagnostic{
+			Range:    d.lineSpan(line),
+			Severity: severity,
+			Source:   source,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	first := len(d.lines)
+	if len(d.nodes) > 0 {
+		first = d.nodes[0].header
+	}
+	for i := range first {
+		if strings.TrimSpace(d.lines[i]) != "" {
+			add(i, severityError, "line is not inside of a node")
+		}
+	}
+
+	maxNodes := constants.MaxGridWidth * constants.MaxGridHeight
+	for i, n := range d.nodes {
+		if i == maxNodes {
+			add(n.header, severityError, "too many nodes: expected <=%d", maxNodes)
+		}
+
+		lines := d.lines[n.start:n.end]
+		for _, err := range emu.CheckCode(lines) {
+			add(n.start+err.Line, severityError, "%s", err.Message)
+		}
+
+		count := 0
+		for j, line := range lines {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			count++
+			if len(line) > constants.MaxLineLength {
+				add(n.start+j, severityWarning, "line has %d characters, at most %d fit in a node", len(line), constants.MaxLineLength)
+			}
+		}
+		if count > constants.MaxNodeLines {
+			add(n.header, severityWarning, "node has %d lines, at most %d fit in a node", count, constants.MaxNodeLines)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Range.Start.Line < diags[j].Range.Start.Line
+	})
+	return diags
+}
+
+// tokenAt returns the token under the position.
+func (d *document) tokenAt(pos position) (emu.Token, bool) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return emu.Token{}, false
+	}
+	for _, tok := range emu.Tokenize(d.lines[pos.Line]) {
+		if tok.Start <= pos.Character && pos.Character <= tok.End && tok.Type != emu.COMMA {
+			return tok, true
+		}
+	}
+	return emu.Token{}, false
+}
+
+func (d *document) hover(pos position) *hover {
+	tok, ok := d.tokenAt(pos)
+	if !ok {
+		return nil
+	}
+
+	var text string
+	switch tok.Type {
+	case emu.OPCODE, emu.REGISTER, emu.PORT:
+		text = docs[tok.Value]
+	case emu.LABEL:
+		if def, ok := d.labelDefinition(pos.Line, tok.Value); ok {
+			text = fmt.Sprintf("label `%s`, defined on line %d", tok.Value, def.Start.Line+1)
+		} else {
+			text = fmt.Sprintf("undefined label `%s`", tok.Value)
+		}
+	}
+	if text == "" {
+		return nil
+	}
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    span{Start: position{pos.Line, tok.Start}, End: position{pos.Line, tok.End}},
+	}
+}
+
+func (d *document) definition(uri string, pos position) *location {
+	tok, ok := d.tokenAt(pos)
+	if !ok || tok.Type != emu.LABEL {
+		return nil
+	}
+	def, ok := d.labelDefinition(pos.Line, tok.Value)
+	if !ok {
+		return nil
+	}
+	return &location{URI: uri, Range: def}
+}
+
+// labelDefinition finds where the label is defined in the node of the line.
+// The span leaves out the colon.
+func (d *document) labelDefinition(line int, label string) (span, bool) {
+	n, ok := d.nodeAt(line)
+	if !ok {
+		return span{}, false
+	}
+	for i := n.start; i < n.end; i++ {
+		tokens := emu.Tokenize(d.lines[i])
+		if len(tokens) > 0 && tokens[0].Type == emu.LABEL && tokens[0].Value == label {
+			return span{Start: position{i, tokens[0].Start}, End: position{i, tokens[0].End - 1}}, true
+		}
+	}
+	return span{}, false
+}
+
+// labels returns the labels defined in the node of the line.
+func (d *document) labels(line int) []string {
+	n, ok := d.nodeAt(line)
+	if !ok {
+		return nil
+	}
+	labels := make([]string, 0)
+	for i := n.start; i < n.end; i++ {
+		tokens := emu.Tokenize(d.lines[i])
+		if len(tokens) > 0 && tokens[0].Type == emu.LABEL && tokens[0].Value != "" {
+			labels = append(labels, tokens[0].Value)
+		}
+	}
+	return labels
+}
+
+// completion offers opcodes while the first word of an instruction is typed,
+// labels of the node for operands of jumps and registers and ports for the
+// operands of other instructions.
+func (d *document) completion(pos position) []completionItem {
+	if _, ok := d.nodeAt(pos.Line); !ok {
+		return []completionItem{}
+	}
+	text := d.lines[pos.Line]
+	text = text[:min(pos.Character, len(text))]
+	tokens := emu.Tokenize(text)
+	if len(tokens) > 0 && tokens[0].Type == emu.LABEL {
+		tokens = tokens[1:]
+	}
+	if len(tokens) > 0 && tokens[len(tokens)-1].Type == emu.COMMENT {
+		return []completionItem{}
+	}
+
+	items := make([]completionItem, 0)
+	if len(tokens) == 0 || (len(tokens) == 1 && tokens[0].End == len(text)) {
+		for _, op := range opcodeNames {
+			items = append(items, completionItem{Label: op, Kind: kindKeyword, Detail: summary(op)})
+		}
+		return items
+	}
+
+	switch tokens[0].Value {
+	case "JMP", "JEZ", "JNZ", "JGZ", "JLZ":
+		for _, label := range d.labels(pos.Line) {
+			items = append(items, completionItem{Label: label, Kind: kindReference})
+		}
+	default:
+		for _, operand := range operandNames {
+			items = append(items, completionItem{Label: operand, Kind: kindVariable, Detail: summary(operand)})
+		}
+	}
+	return items
+}
+
+func summary(name string) string {
+	first, _, _ := strings.Cut(docs[name], "\n")
+	return strings.Trim(first, "`")
+}
+
+var opcodeNames = []string{"MOV", "SWP", "SAV", "ADD", "SUB", "NEG", "NOP", "JMP", "JEZ", "JNZ", "JGZ", "JLZ", "JRO"}
+
+// BAK is left out, as it can not be used as an operand.
+var operandNames = []string{"ACC", "NIL", "UP", "DOWN", "LEFT", "RIGHT", "ANY", "LAST"}
+
+var docs = map[string]string{
+	"MOV":   "`MOV <SRC>, <DST>`\n\nReads a value from SRC and writes it to DST.",
+	"SWP":   "`SWP`\n\nSwaps the values of ACC and BAK.",
+	"SAV":   "`SAV`\n\nWrites the value of ACC to BAK.",
+	"ADD":   "`ADD <SRC>`\n\nAdds the value read from SRC to ACC.",
+	"SUB":   "`SUB <SRC>`\n\nSubtracts the value read from SRC from ACC.",
+	"NEG":   "`NEG`\n\nNegates the value of ACC.",
+	"NOP":   "`NOP`\n\nDoes nothing for a cycle.",
+	"JMP":   "`JMP <LABEL>`\n\nJumps to the label.",
+	"JEZ":   "`JEZ <LABEL>`\n\nJumps to the label if ACC is zero.",
+	"JNZ":   "`JNZ <LABEL>`\n\nJumps to the label if ACC is not zero.",
+	"JGZ":   "`JGZ <LABEL>`\n\nJumps to the label if ACC is greater than zero.",
+	"JLZ":   "`JLZ <LABEL>`\n\nJumps to the label if ACC is less than zero.",
+	"JRO":   "`JRO <SRC>`\n\nJumps by the offset read from SRC, relative to this instruction.",
+	"OUT":   "`OUT`\n\nAppends ACC to the output of the node. Only output streams have one.",
+	"ACC":   "`ACC`\n\nThe accumulator, the register instructions work on.",
+	"BAK":   "`BAK`\n\nThe backup register, only reachable with SAV and SWP.",
+	"NIL":   "`NIL`\n\nReads as zero, values written to it are dropped.",
+	"UP":    "`UP`\n\nThe port to the node above.",
+	"DOWN":  "`DOWN`\n\nThe port to the node below.",
+	"LEFT":  "`LEFT`\n\nThe port to the node on the left.",
+	"RIGHT": "`RIGHT`\n\nThe port to the node on the right.",
+	"ANY": "`ANY`\n\nReads from the first neighbour writing to the node in LEFT, RIGHT, UP, DOWN order, " +
+		"or writes to the first neighbour reading from it in UP, LEFT, RIGHT, DOWN order.",
+	"LAST": "`LAST`\n\nThe port last used by ANY.",
+}
@@ -0,0 +1,207 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// Error codes of JSON-RPC used by the server.
+const (
+	parseError     = -32700
+	methodNotFound = -32601
+	invalidParams  = -32602
+)
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+type server struct {
+	in   *textproto.Reader
+	out  io.Writer
+	docs map[string]*document
+}
+
+// Serve runs a language server for solution files reading requests from r
+// and writing responses to w until the client sends exit or closes r.
+// Documents are synced as a whole and positions count bytes, which are the
+// same as UTF-16 code units for the ASCII code of solutions.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{
+		in:   textproto.NewReader(bufio.NewReader(r)),
+		out:  w,
+		docs: make(map[string]*document),
+	}
+	for {
+		msg, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		// a message that is not JSON is answered, as its id is unknown, with
+		// a null id, and the server goes on with the next one
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			id := json.RawMessage("null")
+			if err := s.write(&message{ID: &id, Error: rpcErr}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) read() (*message, error) {
+	header, err := s.in.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("wrong content length: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in.R, body); err != nil {
+		return nil, err
+	}
+
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, &responseError{Code: parseError, Message: fmt.Sprintf("wrong message: %s", err)}
+	}
+	return msg, nil
+}
+
+func (s *server) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(body)
+	return err
+}
+
+func (s *server) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(&message{Method: method, Params: data})
+}
+
+// handle answers requests with the result of dispatch. Errors of the
+// protocol are sent to the client, others stop the server.
+func (s *server) handle(msg *message) error {
+	result, err := s.dispatch(msg)
+	var rpcErr *responseError
+	if errors.As(err, &rpcErr) {
+		if msg.ID == nil {
+			return nil
+		}
+		return s.write(&message{ID: msg.ID, Error: rpcErr})
+	}
+	if err != nil || msg.ID == nil {
+		return err
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return s.write(&message{ID: msg.ID, Result: result})
+}
+
+// dispatch handles a request or a notification. Notifications of unknown
+// methods are ignored, as the protocol asks for.
+func (s *server) dispatch(msg *message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return initializeResult{Capabilities: capabilities{
+			TextDocumentSync:   1,
+			HoverProvider:      true,
+			DefinitionProvider: true,
+			CompletionProvider: completionOptions{TriggerCharacters: []string{" ", ","}},
+		}}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: invalidParams, Message: err.Error()}
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil || len(params.ContentChanges) == 0 {
+			return nil, &responseError{Code: invalidParams, Message: "wrong changes"}
+		}
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: invalidParams, Message: err.Error()}
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []diagnostic{})
+	case "textDocument/hover", "textDocument/definition", "textDocument/completion":
+		var params positionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{Code: invalidParams, Message: err.Error()}
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, &responseError{Code: invalidParams, Message: "unknown document " + params.TextDocument.URI}
+		}
+		switch msg.Method {
+		case "textDocument/hover":
+			return doc.hover(params.Position), nil
+		case "textDocument/definition":
+			return doc.definition(params.TextDocument.URI, params.Position), nil
+		default:
+			return doc.completion(params.Position), nil
+		}
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: methodNotFound, Message: "unknown method " + msg.Method}
+}
+
+func (s *server) update(uri string, text string) error {
+	doc := parseDocument(text)
+	s.docs[uri] = doc
+	return s.publish(uri, doc.diagnostics())
+}
+
+func (s *server) publish(uri string, diags []diagnostic) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
@@ -0,0 +1,229 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/lsp"
+)
+
+const uri = "file:///solution.tis"
+
+const text = "@1\nL: mov up, acc\njmp l\nfoo\n@2\nMOV LEFT, RIGHT # much too long\n"
+
+/* TESTS */
+
+// Serve
+func TestServeWithDiagnostics(t *testing.T) {
+	responses := serve(t, openRequests()...)
+
+	var params struct {
+		Diagnostics []struct {
+			Range struct {
+				Start struct{ Line int }
+			}
+			Severity int
+			Message  string
+		}
+	}
+	if err := json.Unmarshal(responses[1].Params, &params); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got := make([]string, 0, len(params.Diagnostics))
+	for _, diag := range params.Diagnostics {
+		got = append(got, fmt.Sprintf("%d %d %s", diag.Range.Start.Line, diag.Severity, diag.Message))
+	}
+	expected := []string{
+		"3 1 invalid instruction FOO",
+		"5 2 line has 31 characters, at most 18 fit in a node",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("wrong diagnostics. expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestServeWithHover(t *testing.T) {
+	requests := append(openRequests(), request(2, "textDocument/hover", positionParams(1, 4)))
+	responses := serve(t, requests...)
+
+	var result struct {
+		Contents struct{ Value string }
+	}
+	if err := json.Unmarshal(responses[2].Result, &result); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(result.Contents.Value, "MOV <SRC>, <DST>") {
+		t.Errorf("wrong hover: %s", result.Contents.Value)
+	}
+}
+
+func TestServeWithDefinition(t *testing.T) {
+	requests := append(openRequests(), request(2, "textDocument/definition", positionParams(2, 4)))
+	responses := serve(t, requests...)
+
+	var result struct {
+		URI   string
+		Range struct {
+			Start struct{ Line, Character int }
+			End   struct{ Line, Character int }
+		}
+	}
+	if err := json.Unmarshal(responses[2].Result, &result); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.URI != uri || result.Range.Start.Line != 1 || result.Range.Start.Character != 0 || result.Range.End.Character != 1 {
+		t.Errorf("wrong definition: %+v", result)
+	}
+}
+
+func TestServeWithCompletion(t *testing.T) {
+	requests := append(
+		openRequests(),
+		request(2, "textDocument/completion", positionParams(2, 4)),
+		request(3, "textDocument/completion", positionParams(1, 3)),
+	)
+	responses := serve(t, requests...)
+
+	labels := func(resp response) []string {
+		var items []struct{ Label string }
+		if err := json.Unmarshal(resp.Result, &items); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		names := make([]string, 0, len(items))
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return names
+	}
+	if got := labels(responses[2]); !reflect.DeepEqual(got, []string{"L"}) {
+		t.Errorf("wrong label completion. expected: [L], got: %v", got)
+	}
+	if got := labels(responses[3]); len(got) == 0 || got[0] != "MOV" {
+		t.Errorf("wrong opcode completion: %v", got)
+	}
+}
+
+func TestServeWithUnknownMethod(t *testing.T) {
+	responses := serve(t, request(1, "workspace/symbol", map[string]any{}))
+	if responses[0].Error == nil || responses[0].Error.Code != -32601 {
+		t.Errorf("expected method not found error, got: %+v", responses[0])
+	}
+}
+
+func TestServeWithWrongMessage(t *testing.T) {
+	good, err := json.Marshal(request(1, "initialize", map[string]any{}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	responses := serveBodies(t, []byte(`{"jsonrpc": "2.0", "id": 1, "method":`), good)
+
+	if len(responses) != 2 {
+		t.Fatalf("wrong responses number. expected: 2, got: %d", len(responses))
+	}
+	if responses[0].ID != nil || responses[0].Error == nil || responses[0].Error.Code != -32700 {
+		t.Errorf("wrong response. expected parse error with null id, got: %+v", responses[0])
+	}
+	if responses[1].ID == nil || *responses[1].ID != 1 || responses[1].Error != nil {
+		t.Errorf("wrong response. expected result of request 1, got: %+v", responses[1])
+	}
+}
+
+/* UTILS */
+type response struct {
+	ID     *int
+	Method string
+	Params json.RawMessage
+	Result json.RawMessage
+	Error  *struct{ Code int }
+}
+
+func request(id int, method string, params any) map[string]any {
+	req := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		req["id"] = id
+	}
+	return req
+}
+
+func openRequests() []map[string]any {
+	return []map[string]any{
+		request(1, "initialize", map[string]any{}),
+		request(0, "textDocument/didOpen", map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "tis", "version": 1, "text": text},
+		}),
+	}
+}
+
+func positionParams(line int, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// serve runs the server with the requests followed by shutdown and exit and
+// returns everything it sent but the response to shutdown.
+func serve(t *testing.T, requests ...map[string]any) []response {
+	t.Helper()
+	bodies := make([][]byte, 0, len(requests))
+	for _, req := range requests {
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		bodies = append(bodies, body)
+	}
+	return serveBodies(t, bodies...)
+}
+
+// serveBodies is serve with the bodies of the requests sent as they are.
+func serveBodies(t *testing.T, bodies ...[]byte) []response {
+	t.Helper()
+	for _, req := range []map[string]any{request(99, "shutdown", nil), request(0, "exit", nil)} {
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		bodies = append(bodies, body)
+	}
+
+	var in bytes.Buffer
+	for _, body := range bodies {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out bytes.Buffer
+	if err := lsp.Serve(&in, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	responses := make([]response, 0)
+	r := textproto.NewReader(bufio.NewReader(&out))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r.R, body); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var resp response
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses[:len(responses)-1]
+}
@@ -0,0 +1,306 @@
+package minimizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// Result is the smallest variant of a solution that was found. Scores take
+// the cycles of the slowest puzzle, Before is the score of the solution and
+// Runs counts the variants tried.
+type Result struct {
+	Code   types.ProgramCode
+	Score  types.Score
+	Before types.Score
+	Runs   int
+}
+
+type minimizer struct {
+	ctx       context.Context
+	puzzles   []*types.Puzzle
+	workers   int
+	maxCycles int
+}
+
+// Minimize removes whole nodes, removes single instructions and merges pairs
+// of instructions of code as long as it still passes every puzzle, which are
+// usually a single puzzle loaded with different seeds. Every variant has
+// fewer instructions than the code it comes from, so the first passing one is
+// kept and the search starts again from it until no variant passes. Puzzles
+// of a variant run on at most workers goroutines. Once ctx is done the
+// smallest code found so far is returned along with the error of ctx.
+func Minimize(
+	ctx context.Context,
+	puzzles []*types.Puzzle,
+	code types.ProgramCode,
+	workers int,
+	maxCycles int,
+) (Result, error) {
+	m := minimizer{ctx: ctx, puzzles: puzzles, workers: workers, maxCycles: maxCycles}
+	passed, score, err := m.check(code)
+	if err != nil {
+		return Result{}, err
+	}
+	if !passed {
+		return Result{}, errors.New("solution does not pass")
+	}
+
+	res := Result{Code: code, Score: score, Before: score}
+	for found := true; found; {
+		found = false
+		for _, variant := range variants(res.Code) {
+			res.Runs++
+			passed, score, err := m.check(variant)
+			if err != nil {
+				return res, err
+			}
+			if passed {
+				res.Code, res.Score = variant, score
+				found = true
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// check runs code against every puzzle. Code that does not assemble does not
+// pass, only the error of ctx is returned.
+func (m minimizer) check(code types.ProgramCode) (bool, types.Score, error) {
+	jobs := make([]runner.Job, 0, len(m.puzzles))
+	for _, puzzle := range m.puzzles {
+		jobs = append(jobs, runner.Job{Puzzle: puzzle, Code: code})
+	}
+	results, err := runner.RunBatch(m.ctx, jobs, m.workers, m.maxCycles)
+	if err != nil {
+		return false, types.Score{}, err
+	}
+
+	score := types.Score{}
+	for _, res := range results {
+		if res.Err != nil || !res.Passed {
+			return false, types.Score{}, nil
+		}
+		score.Cycles = max(score.Cycles, res.Score.Cycles)
+		score.Nodes = res.Score.Nodes
+		score.Instructions = res.Score.Instructions
+	}
+	return true, score, nil
+}
+
+// variants returns the changes of code to try, from the ones removing the
+// most instructions to the ones removing a single one.
+func variants(code types.ProgramCode) []types.ProgramCode {
+	result := make([]types.ProgramCode, 0)
+	for i, lines := range code.NodesCode {
+		if next(lines, -1) >= 0 {
+			result = append(result, withNode(code, i, nil))
+		}
+	}
+	for i, lines := range code.NodesCode {
+		for j := range lines {
+			if merged, ok := merge(lines, j); ok {
+				result = append(result, withNode(code, i, merged))
+			}
+		}
+	}
+	for i, lines := range code.NodesCode {
+		for j := range lines {
+			if removed, ok := remove(lines, j); ok {
+				result = append(result, withNode(code, i, removed))
+			}
+		}
+	}
+	return result
+}
+
+func withNode(code types.ProgramCode, i int, lines []string) types.ProgramCode {
+	nodesCode := append([][]string{}, code.NodesCode...)
+	nodesCode[i] = lines
+	return types.ProgramCode{Title: code.Title, NodesCode: nodesCode}
+}
+
+// line is a line of code split into its label and the tokens of its
+// instruction without the comment.
+type line struct {
+	label string
+	body  []emu.Token
+}
+
+func parseLine(text string) line {
+	tokens := emu.Tokenize(text)
+	if len(tokens) > 0 && tokens[len(tokens)-1].Type == emu.COMMENT {
+		tokens = tokens[:len(tokens)-1]
+	}
+	l := line{}
+	if len(tokens) > 0 && tokens[0].Type == emu.LABEL {
+		l.label = tokens[0].Value
+		tokens = tokens[1:]
+	}
+	l.body = tokens
+	return l
+}
+
+// instruction reports whether the line assembles into an instruction, which
+// is true for lines with just a label as well.
+func (l line) instruction() bool {
+	return l.label != "" || len(l.body) > 0
+}
+
+// operands returns the opcode and the operands of the instruction.
+func (l line) operands() (string, []string) {
+	if len(l.body) == 0 {
+		return "", nil
+	}
+	operands := make([]string, 0, 2)
+	for _, tok := range l.body[1:] {
+		if tok.Type != emu.COMMA {
+			operands = append(operands, tok.Value)
+		}
+	}
+	return l.body[0].Value, operands
+}
+
+// next returns the index of the first instruction line after line j, or -1.
+func next(lines []string, j int) int {
+	for k := j + 1; k < len(lines); k++ {
+		if parseLine(lines[k]).instruction() {
+			return k
+		}
+	}
+	return -1
+}
+
+// remove removes the instruction of line j. Its label moves to the next
+// instruction, which is the first one when line j is the last, as jumps to
+// the label then land where the removed instruction would have led. Labels
+// only the removed instruction jumped to are dropped.
+func remove(lines []string, j int) ([]string, bool) {
+	l := parseLine(lines[j])
+	if !l.instruction() {
+		return nil, false
+	}
+	result := append(append([]string{}, lines[:j]...), lines[j+1:]...)
+	for _, tok := range l.body {
+		if tok.Type == emu.LABEL && !referenced(result, tok.Value) {
+			dropLabel(result, tok.Value)
+		}
+	}
+	if l.label == "" || !referenced(result, l.label) {
+		return result, true
+	}
+
+	k := next(result, j-1)
+	if k < 0 {
+		k = next(result, -1)
+	}
+	if k < 0 {
+		return nil, false
+	}
+	if target := parseLine(result[k]).label; target != "" {
+		for i := range result {
+			result[i] = renameLabel(result[i], l.label, target)
+		}
+	} else {
+		result[k] = l.label + ": " + strings.TrimSpace(result[k])
+	}
+	return result, true
+}
+
+// merge replaces line j and the next instruction with a single instruction
+// doing the same. The next instruction must not have a label, as jumps to it
+// would skip the first half of the merged instruction.
+func merge(lines []string, j int) ([]string, bool) {
+	k := next(lines, j)
+	if k < 0 {
+		return nil, false
+	}
+	first, second := parseLine(lines[j]), parseLine(lines[k])
+	if second.label != "" {
+		return nil, false
+	}
+
+	instruction, ok := mergeInstructions(first, second)
+	if !ok {
+		return nil, false
+	}
+	if first.label != "" {
+		instruction = first.label + ": " + instruction
+	}
+	result := append([]string{}, lines[:j]...)
+	result = append(result, instruction)
+	result = append(result, lines[j+1:k]...)
+	return append(result, lines[k+1:]...), true
+}
+
+func mergeInstructions(first line, second line) (string, bool) {
+	op1, args1 := first.operands()
+	op2, args2 := second.operands()
+	switch {
+	case op1 == "MOV" && op2 == "MOV" && len(args1) == 2 && len(args2) == 2 &&
+		args1[1] == "ACC" && args2[0] == "ACC":
+		return fmt.Sprintf("MOV %s, %s", args1[0], args2[1]), true
+	case (op1 == "ADD" || op1 == "SUB") && (op2 == "ADD" || op2 == "SUB") && len(args1) == 1 && len(args2) == 1:
+		a, err1 := strconv.Atoi(args1[0])
+		b, err2 := strconv.Atoi(args2[0])
+		if err1 != nil || err2 != nil {
+			return "", false
+		}
+		if op1 == "SUB" {
+			a = -a
+		}
+		if op2 == "SUB" {
+			b = -b
+		}
+		sum := a + b
+		if sum < constants.MinACC || sum > constants.MaxACC {
+			return "", false
+		}
+		if sum < 0 {
+			return fmt.Sprintf("SUB %d", -sum), true
+		}
+		return fmt.Sprintf("ADD %d", sum), true
+	}
+	return "", false
+}
+
+// referenced reports whether any jump of the lines goes to label.
+func referenced(lines []string, label string) bool {
+	for _, text := range lines {
+		for i, tok := range emu.Tokenize(text) {
+			if i > 0 && tok.Type == emu.LABEL && tok.Value == label {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dropLabel removes the definition of label unless it is the whole line, as
+// the line would no longer be an instruction then.
+func dropLabel(lines []string, label string) {
+	for i, text := range lines {
+		tokens := emu.Tokenize(text)
+		if len(tokens) > 1 && tokens[0].Type == emu.LABEL && tokens[0].Value == label {
+			lines[i] = strings.TrimSpace(text[tokens[0].End:])
+		}
+	}
+}
+
+func renameLabel(text string, from string, to string) string {
+	tokens := emu.Tokenize(text)
+	for i := len(tokens) - 1; i > 0; i-- {
+		if tok := tokens[i]; tok.Type == emu.LABEL && tok.Value == from {
+			text = text[:tok.Start] + to + text[tok.End:]
+		}
+	}
+	return text
+}
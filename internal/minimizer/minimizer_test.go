@@ -0,0 +1,81 @@
+package minimizer_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/minimizer"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Minimize
+func TestMinimize(t *testing.T) {
+	code := types.ProgramCode{Title: "TEST", NodesCode: [][]string{
+		{"MOV UP, ACC", "ADD 1", "# ADD TWO", "ADD 2", "MOV ACC, DOWN", "NOP", "JMP L", "L: SAV"},
+		{"MOV 5, ACC"},
+	}}
+	res, err := minimizer.Minimize(context.Background(), newPuzzles(3), code, 0, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := [][]string{{"MOV UP, ACC", "ADD 3", "# ADD TWO", "MOV ACC, DOWN"}, nil}
+	if !reflect.DeepEqual(res.Code.NodesCode, expected) {
+		t.Errorf("wrong code. expected: %q, got: %q", expected, res.Code.NodesCode)
+	}
+	if expected := (types.Score{Cycles: 8, Nodes: 1, Instructions: 3}); res.Score != expected {
+		t.Errorf("wrong score. expected: %+v, got: %+v", expected, res.Score)
+	}
+}
+
+func TestMinimizeWithLabels(t *testing.T) {
+	code := types.ProgramCode{Title: "TEST", NodesCode: [][]string{
+		{"S: MOV UP, ACC", "JEZ S", "JMP N", "N: NOP", "MOV ACC, DOWN", "JMP S"},
+	}}
+	res, err := minimizer.Minimize(context.Background(), newPuzzles(0), code, 0, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := [][]string{{"MOV UP, DOWN"}}
+	if !reflect.DeepEqual(res.Code.NodesCode, expected) {
+		t.Errorf("wrong code. expected: %q, got: %q", expected, res.Code.NodesCode)
+	}
+}
+
+func TestMinimizeWithFailingSolution(t *testing.T) {
+	code := types.ProgramCode{Title: "TEST", NodesCode: [][]string{{"MOV UP, DOWN"}}}
+	puzzles := newPuzzles(3)
+	puzzles[0].Streams[1].Values = []int16{2, 3}
+	_, err := minimizer.Minimize(context.Background(), puzzles, code, 0, constants.MaxCycles)
+	if err == nil {
+		t.Fatal("expected to occure error, got nil")
+	}
+}
+
+/* UTILS */
+// newPuzzles returns puzzles expecting add added to every input value.
+func newPuzzles(add int16) []*types.Puzzle {
+	puzzles := make([]*types.Puzzle, 0, 2)
+	for _, values := range [][]int16{{1, 2}, {5, 4}} {
+		expected := make([]int16, 0, len(values))
+		for _, value := range values {
+			expected = append(expected, value+add)
+		}
+		puzzles = append(puzzles, &types.Puzzle{
+			Title:  "TEST",
+			Width:  2,
+			Height: 1,
+			Layout: []types.NodeType{constants.COMPUTE, constants.COMPUTE},
+			Streams: []types.Stream{
+				{Type: constants.INPUT, Name: "IN", Position: 0, Values: values},
+				{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: expected},
+			},
+		})
+	}
+	return puzzles
+}
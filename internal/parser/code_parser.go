@@ -3,6 +3,7 @@ package parser
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,21 +26,29 @@ func SaveCode(dirPath string, code *types.ProgramCode) (string, error) {
 	}
 	defer file.Close()
 
+	if err = WriteCode(file, code); err != nil {
+		return "", fmt.Errorf("error while writing data to file %s: %w", filePath, err)
+	}
+
+	return file.Name(), nil
+}
+
+// WriteCode writes code in the format of solution files.
+func WriteCode(w io.Writer, code *types.ProgramCode) error {
 	for i, node := range code.NodesCode {
-		if _, err = file.WriteString(fmt.Sprintf("@%d\n", i+1)); err != nil {
-			return "", fmt.Errorf("error while writing data to file %s: %w", filePath, err)
+		if _, err := fmt.Fprintf(w, "@%d\n", i+1); err != nil {
+			return err
 		}
 		for _, str := range node {
-			if _, err = file.WriteString(str + "\n"); err != nil {
-				return "", fmt.Errorf("error while writing data to file %s: %w", filePath, err)
+			if _, err := io.WriteString(w, str+"\n"); err != nil {
+				return err
 			}
 		}
-		if _, err = file.WriteString("\n"); err != nil {
-			return "", fmt.Errorf("error while writing data to file %s: %w", filePath, err)
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
 		}
 	}
-
-	return file.Name(), nil
+	return nil
 }
 
 func FetchCode(fileName string) (*types.ProgramCode, error) {
@@ -49,8 +58,19 @@ func FetchCode(fileName string) (*types.ProgramCode, error) {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	nodesCode := make([][]string, constants.NodesNumber)
+	title := filepath.Base(file.Name())
+	title = strings.ToUpper(strings.TrimSuffix(title, ".tis"))
+
+	code, err := ParseCode(title, file)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading file %s: %w", fileName, err)
+	}
+	return code, nil
+}
+
+func ParseCode(title string, r io.Reader) (*types.ProgramCode, error) {
+	scanner := bufio.NewScanner(r)
+	nodesCode := make([][]string, 0)
 
 	var line string
 	curNode := -1
@@ -62,19 +82,23 @@ func FetchCode(fileName string) (*types.ProgramCode, error) {
 
 		if strings.HasPrefix(line, "@") {
 			curNode++
+			if curNode >= constants.MaxGridWidth*constants.MaxGridHeight {
+				return nil, fmt.Errorf("too many nodes: expected <=%d", constants.MaxGridWidth*constants.MaxGridHeight)
+			}
+			nodesCode = append(nodesCode, nil)
 			continue
 		}
+		if curNode < 0 {
+			return nil, fmt.Errorf("line %q is not inside of a node", line)
+		}
 
 		nodesCode[curNode] = append(nodesCode[curNode], line)
 	}
 
-	if err = scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error while reading file %s: %w", fileName, err)
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	title := filepath.Base(file.Name())
-	title = strings.ToUpper(strings.TrimSuffix(title, ".tis"))
-
 	return &types.ProgramCode{
 		Title:     title,
 		NodesCode: nodesCode,
@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/yuin/gopher-lua"
+
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+var portNames = []string{"UP", "RIGHT", "DOWN", "LEFT"}
+
+// fetchConnections returns the connections of the optional GetConnections
+// function. Each connection is described by an array of node, port, node,
+// port and an optional boolean making it one way, nil means the grid wiring.
+func fetchConnections(L *lua.LState) ([]types.Connection, error) {
+	if L.GetGlobal("GetConnections") == lua.LNil {
+		return nil, nil
+	}
+	runResult, err := runLuaFunction(L, "GetConnections")
+	if err != nil {
+		return nil, err
+	}
+	connectionsTable, ok := runResult.(*lua.LTable)
+	if !ok {
+		return nil, errors.New("connections is not an array")
+	}
+
+	connections := make([]types.Connection, 0, connectionsTable.Len())
+	connectionsTable.ForEach(func(_, value lua.LValue) {
+		if err != nil {
+			return
+		}
+		connectionTable, ok := value.(*lua.LTable)
+		if !ok {
+			err = errors.New("connection is not an array")
+			return
+		}
+		if connectionTable.Len() != 4 && connectionTable.Len() != 5 {
+			err = fmt.Errorf("wrong connection arguments number: expected 4 or 5, got %d", connectionTable.Len())
+			return
+		}
+
+		from, fromOk := connectionTable.RawGetInt(1).(lua.LNumber)
+		to, toOk := connectionTable.RawGetInt(3).(lua.LNumber)
+		if !fromOk || !toOk {
+			err = errors.New("node of connection is not a number")
+			return
+		}
+		fromPort, fromErr := parsePort(connectionTable.RawGetInt(2).String())
+		toPort, toErr := parsePort(connectionTable.RawGetInt(4).String())
+		if err = errors.Join(fromErr, toErr); err != nil {
+			return
+		}
+		oneWay := false
+		if connectionTable.Len() == 5 {
+			value, ok := connectionTable.RawGetInt(5).(lua.LBool)
+			if !ok {
+				err = errors.New("fifth value of connection is not a boolean")
+				return
+			}
+			oneWay = bool(value)
+		}
+
+		connections = append(connections, types.Connection{
+			From:     int(from),
+			FromPort: fromPort,
+			To:       int(to),
+			ToPort:   toPort,
+			OneWay:   oneWay,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+func parsePort(name string) (types.Port, error) {
+	port, ok := indexOf(portNames, name)
+	if !ok {
+		return 0, fmt.Errorf("unknown port %q", name)
+	}
+	return types.Port(port), nil
+}
+
+// checkWiring makes sure the connections of the puzzle are valid and leave
+// the ports of every stream free.
+func checkWiring(puzzle *types.Puzzle) error {
+	if puzzle.Connections == nil {
+		return nil
+	}
+	p := emu.NewGrid(puzzle.Width, puzzle.Height)
+	if err := p.Connect(puzzle.Connections); err != nil {
+		return err
+	}
+	// a copy, so appending never writes into spare capacity of puzzle.Streams
+	streams := slices.Clone(puzzle.Streams)
+	for _, test := range puzzle.Tests {
+		streams = append(streams, test.Streams...)
+	}
+	for _, stream := range streams {
+		if p.StreamPort(stream) != nil {
+			return fmt.Errorf("port of stream %s is already connected", stream.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+var (
+	streamTypeNames = []string{"INPUT", "OUTPUT"}
+	nodeTypeNames   = []string{"COMPUTE", "DAMAGED"}
+)
+
+type puzzleFile struct {
+	Title       string           `json:"title" yaml:"title"`
+	Description []string         `json:"description" yaml:"description"`
+	Streams     []streamFile     `json:"streams,omitempty" yaml:"streams,omitempty"`
+	Tests       []testFile       `json:"tests,omitempty" yaml:"tests,omitempty"`
+	Width       int              `json:"width,omitempty" yaml:"width,omitempty"`
+	Height      int              `json:"height,omitempty" yaml:"height,omitempty"`
+	Layout      []string         `json:"layout" yaml:"layout"`
+	Connections []connectionFile `json:"connections,omitempty" yaml:"connections,omitempty"`
+}
+
+type connectionFile struct {
+	From     int    `json:"from" yaml:"from"`
+	FromPort string `json:"from_port" yaml:"from_port"`
+	To       int    `json:"to" yaml:"to"`
+	ToPort   string `json:"to_port" yaml:"to_port"`
+	OneWay   bool   `json:"one_way,omitempty" yaml:"one_way,omitempty"`
+}
+
+type streamFile struct {
+	Type     string  `json:"type" yaml:"type"`
+	Name     string  `json:"name" yaml:"name"`
+	Position int     `json:"position" yaml:"position"`
+	Values   []int16 `json:"values" yaml:"values,flow"`
+}
+
+type testFile struct {
+	Name    string       `json:"name" yaml:"name"`
+	Streams []streamFile `json:"streams" yaml:"streams"`
+}
+
+// LoadPuzzle fetches a puzzle from a Lua script or a JSON or YAML file
+// depending on the extension of fileName.
+func LoadPuzzle(fileName string, opts PuzzleOptions) (*types.Puzzle, error) {
+	switch filepath.Ext(fileName) {
+	case ".lua":
+		return FetchPuzzleWithOptions(fileName, opts)
+	case ".json":
+		return FetchJSONPuzzle(fileName)
+	case ".yaml", ".yml":
+		return FetchYAMLPuzzle(fileName)
+	default:
+		return nil, fmt.Errorf("unsupported puzzle format %s", fileName)
+	}
+}
+
+// ParsePuzzle is LoadPuzzle for puzzles that are already read, name is only
+// used to find out the format and in errors.
+func ParsePuzzle(name string, data []byte, opts PuzzleOptions) (*types.Puzzle, error) {
+	switch filepath.Ext(name) {
+	case ".lua":
+		return ParseLuaPuzzle(name, data, opts)
+	case ".json", ".yaml", ".yml":
+		parse := ParseJSONPuzzle
+		if filepath.Ext(name) != ".json" {
+			parse = ParseYAMLPuzzle
+		}
+		puzzle, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid puzzle file %s: %w", name, err)
+		}
+		return puzzle, nil
+	default:
+		return nil, fmt.Errorf("unsupported puzzle format %s", name)
+	}
+}
+
+func isPuzzleFile(fileName string) bool {
+	ext := filepath.Ext(fileName)
+	return ext == ".lua" || ext == ".yaml" || ext == ".yml" ||
+		(ext == ".json" && filepath.Base(fileName) != constants.ScoresFileName)
+}
+
+func FetchJSONPuzzle(fileName string) (*types.Puzzle, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", fileName, err)
+	}
+	puzzle, err := ParseJSONPuzzle(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid puzzle file %s: %w", fileName, err)
+	}
+	return puzzle, nil
+}
+
+func ParseJSONPuzzle(data []byte) (*types.Puzzle, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file puzzleFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+	return file.puzzle()
+}
+
+// puzzle checks a decoded puzzle file the same way whatever format it is
+// written in.
+func (file *puzzleFile) puzzle() (*types.Puzzle, error) {
+	if file.Title == "" {
+		return nil, errors.New("title is empty")
+	}
+	puzzle := &types.Puzzle{
+		Title:       file.Title,
+		Description: file.Description,
+		Width:       constants.DefaultGridWidth,
+		Height:      constants.DefaultGridHeight,
+	}
+	if puzzle.Description == nil {
+		puzzle.Description = []string{}
+	}
+	if file.Width != 0 || file.Height != 0 {
+		if err := checkGrid(file.Width, file.Height); err != nil {
+			return nil, err
+		}
+		puzzle.Width, puzzle.Height = file.Width, file.Height
+	}
+
+	for _, test := range file.Tests {
+		streams, err := parseStreamFiles(test.Streams, puzzle.Width)
+		if err != nil {
+			return nil, fmt.Errorf("test %s: %w", test.Name, err)
+		}
+		puzzle.Tests = append(puzzle.Tests, types.Test{Name: test.Name, Streams: streams})
+	}
+	var err error
+	if file.Streams == nil && puzzle.Tests != nil {
+		puzzle.Streams = puzzle.Tests[0].Streams
+	} else if puzzle.Streams, err = parseStreamFiles(file.Streams, puzzle.Width); err != nil {
+		return nil, err
+	}
+
+	if len(file.Layout) != puzzle.Width*puzzle.Height {
+		return nil, fmt.Errorf("wrong nodes number: expected %d, got %d", puzzle.Width*puzzle.Height, len(file.Layout))
+	}
+	puzzle.Layout = make([]types.NodeType, 0, len(file.Layout))
+	for _, name := range file.Layout {
+		nodeType, ok := indexOf(nodeTypeNames, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown node type %q", name)
+		}
+		puzzle.Layout = append(puzzle.Layout, types.NodeType(nodeType))
+	}
+
+	for _, c := range file.Connections {
+		fromPort, fromErr := parsePort(c.FromPort)
+		toPort, toErr := parsePort(c.ToPort)
+		if err = errors.Join(fromErr, toErr); err != nil {
+			return nil, err
+		}
+		puzzle.Connections = append(puzzle.Connections, types.Connection{
+			From:     c.From,
+			FromPort: fromPort,
+			To:       c.To,
+			ToPort:   toPort,
+			OneWay:   c.OneWay,
+		})
+	}
+	if err = checkWiring(puzzle); err != nil {
+		return nil, err
+	}
+
+	return puzzle, nil
+}
+
+func parseStreamFiles(files []streamFile, width int) ([]types.Stream, error) {
+	streams := make([]types.Stream, 0, len(files))
+	for _, file := range files {
+		streamType, ok := indexOf(streamTypeNames, file.Type)
+		if !ok {
+			return nil, fmt.Errorf("stream %s: unknown stream type %q", file.Name, file.Type)
+		}
+		if file.Position < 0 || file.Position >= width {
+			return nil, fmt.Errorf("stream %s: position is not in range from 0 to %d", file.Name, width-1)
+		}
+		if len(file.Values) > constants.MaxStreamValuesLength {
+			return nil, fmt.Errorf(
+				"stream %s: wrong stream values number: expected <=%d, got %d",
+				file.Name,
+				constants.MaxStreamValuesLength,
+				len(file.Values),
+			)
+		}
+		for _, value := range file.Values {
+			if value < constants.MinACC || value > constants.MaxACC {
+				return nil, fmt.Errorf(
+					"stream %s: stream value is not in range from %d to %d",
+					file.Name,
+					constants.MinACC,
+					constants.MaxACC,
+				)
+			}
+		}
+		values := file.Values
+		if values == nil {
+			values = []int16{}
+		}
+
+		streams = append(streams, types.Stream{
+			Type:     types.StreamType(streamType),
+			Name:     file.Name,
+			Position: uint8(file.Position),
+			Values:   values,
+		})
+	}
+	return streams, nil
+}
+
+// ExportPuzzle writes puzzle in the JSON format. Puzzles validated by a
+// function can not be exported as the format only holds values.
+func ExportPuzzle(w io.Writer, puzzle *types.Puzzle) error {
+	file, err := newPuzzleFile(puzzle)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+func newPuzzleFile(puzzle *types.Puzzle) (*puzzleFile, error) {
+	if puzzle.Validator != nil {
+		return nil, errors.New("puzzle with a Validate function can not be exported")
+	}
+
+	file := &puzzleFile{
+		Title:       puzzle.Title,
+		Description: puzzle.Description,
+		Streams:     streamFiles(puzzle.Streams),
+		Width:       puzzle.Width,
+		Height:      puzzle.Height,
+		Layout:      make([]string, 0, len(puzzle.Layout)),
+	}
+	for _, test := range puzzle.Tests {
+		file.Tests = append(file.Tests, testFile{Name: test.Name, Streams: streamFiles(test.Streams)})
+	}
+	for _, nodeType := range puzzle.Layout {
+		file.Layout = append(file.Layout, nodeTypeNames[nodeType])
+	}
+	for _, c := range puzzle.Connections {
+		file.Connections = append(file.Connections, connectionFile{
+			From:     c.From,
+			FromPort: portNames[c.FromPort],
+			To:       c.To,
+			ToPort:   portNames[c.ToPort],
+			OneWay:   c.OneWay,
+		})
+	}
+	return file, nil
+}
+
+func streamFiles(streams []types.Stream) []streamFile {
+	files := make([]streamFile, 0, len(streams))
+	for _, stream := range streams {
+		files = append(files, streamFile{
+			Type:     streamTypeNames[stream.Type],
+			Name:     stream.Name,
+			Position: int(stream.Position),
+			Values:   stream.Values,
+		})
+	}
+	return files
+}
+
+func indexOf(names []string, name string) (int, bool) {
+	for i, n := range names {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,253 @@
+package parser_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// LoadPuzzle
+func TestLoadPuzzleWithJSONFile(t *testing.T) {
+	path := SetupJSON(t, `{
+		"title": "TEST",
+		"description": ["TEST LINE 1"],
+		"streams": [
+			{ "type": "INPUT", "name": "IN.TEST", "position": 1, "values": [1, 2] },
+			{ "type": "OUTPUT", "name": "OUT.TEST", "position": 2, "values": [2, 4] }
+		],
+		"layout": [
+			"COMPUTE", "DAMAGED", "COMPUTE", "COMPUTE",
+			"COMPUTE", "COMPUTE", "COMPUTE", "COMPUTE",
+			"COMPUTE", "COMPUTE", "COMPUTE", "COMPUTE"
+		]
+	}`)
+
+	puzzle, err := parser.LoadPuzzle(path, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedStreams := []types.Stream{
+		{Type: constants.INPUT, Name: "IN.TEST", Position: 1, Values: []int16{1, 2}},
+		{Type: constants.OUTPUT, Name: "OUT.TEST", Position: 2, Values: []int16{2, 4}},
+	}
+	if puzzle.Title != "TEST" || !reflect.DeepEqual(puzzle.Description, []string{"TEST LINE 1"}) {
+		t.Errorf("wrong title or description: %s %v", puzzle.Title, puzzle.Description)
+	}
+	if !reflect.DeepEqual(puzzle.Streams, expectedStreams) {
+		t.Errorf("wrong streams. expected: %v, got: %v", expectedStreams, puzzle.Streams)
+	}
+	if puzzle.Layout[1] != constants.DAMAGED {
+		t.Error("second node is expected to be damaged")
+	}
+}
+
+func TestLoadPuzzleWithWrongJSONFile(t *testing.T) {
+	cases := []struct {
+		content     string
+		expectedErr string
+	}{
+		{`{ "title": "TEST", "layout": [] }`, "wrong nodes number: expected 12, got 0"},
+		{`{ "title": "" }`, "title is empty"},
+		{`{ "title": "TEST", "tiles": [] }`, "unknown field \"tiles\""},
+		{
+			`{ "title": "TEST", "streams": [{ "type": "BOTH", "name": "IN" }] }`,
+			"stream IN: unknown stream type \"BOTH\"",
+		},
+		{
+			`{ "title": "TEST", "streams": [{ "type": "INPUT", "name": "IN", "position": 4 }] }`,
+			"stream IN: position is not in range from 0 to 3",
+		},
+		{
+			`{ "title": "TEST", "streams": [{ "type": "INPUT", "name": "IN", "values": [1000] }] }`,
+			"stream IN: stream value is not in range from -999 to 999",
+		},
+	}
+	for _, c := range cases {
+		_, err := parser.LoadPuzzle(SetupJSON(t, c.content), parser.PuzzleOptions{})
+		if err == nil {
+			t.Errorf("expected to occure error for %s", c.content)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.expectedErr) {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestLoadPuzzleWithYAMLFile(t *testing.T) {
+	path := SetupYAML(t, `
+title: TEST
+description:
+  - TEST LINE 1
+streams:
+  - { type: INPUT, name: IN.TEST, position: 1, values: [1, 2] }
+  - { type: OUTPUT, name: OUT.TEST, position: 2, values: [2, 4] }
+layout:
+  - COMPUTE
+  - DAMAGED
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+  - COMPUTE
+`)
+
+	puzzle, err := parser.LoadPuzzle(path, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedStreams := []types.Stream{
+		{Type: constants.INPUT, Name: "IN.TEST", Position: 1, Values: []int16{1, 2}},
+		{Type: constants.OUTPUT, Name: "OUT.TEST", Position: 2, Values: []int16{2, 4}},
+	}
+	if puzzle.Title != "TEST" || !reflect.DeepEqual(puzzle.Description, []string{"TEST LINE 1"}) {
+		t.Errorf("wrong title or description: %s %v", puzzle.Title, puzzle.Description)
+	}
+	if !reflect.DeepEqual(puzzle.Streams, expectedStreams) {
+		t.Errorf("wrong streams. expected: %v, got: %v", expectedStreams, puzzle.Streams)
+	}
+	if puzzle.Layout[1] != constants.DAMAGED {
+		t.Error("second node is expected to be damaged")
+	}
+}
+
+func TestLoadPuzzleWithWrongYAMLFile(t *testing.T) {
+	cases := []struct {
+		content     string
+		expectedErr string
+	}{
+		{"", "file is empty"},
+		{"title: TEST\nlayout: []", "wrong nodes number: expected 12, got 0"},
+		{"title: TEST\ntiles: []", "field tiles not found"},
+		{"title: TEST\nstreams:\n  - { type: INPUT, name: IN, position: 4 }", "stream IN: position is not in range from 0 to 3"},
+		{"title: [TEST", "yaml:"},
+	}
+	for _, c := range cases {
+		_, err := parser.LoadPuzzle(SetupYAML(t, c.content), parser.PuzzleOptions{})
+		if err == nil {
+			t.Errorf("expected to occure error for %s", c.content)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.expectedErr) {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestLoadPuzzleWithUnsupportedFormat(t *testing.T) {
+	_, err := parser.LoadPuzzle("puzzle.toml", parser.PuzzleOptions{})
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "unsupported puzzle format puzzle.toml"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+// ExportPuzzle
+func TestExportPuzzleWithSeed(t *testing.T) {
+	script := NewScript()
+	script.GetStreams = []string{
+		"function GetStreams()",
+		"local values = {}",
+		"for i = 1, 10 do values[i] = math.random(-99, 99) end",
+		"return {",
+		"{ STREAM_INPUT, \"IN.TEST\", 0, values },",
+		"{ STREAM_OUTPUT, \"OUT.TEST\", 0, values },",
+		"}",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_export_puzzle_with_seed.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exports := make([]string, 0, 2)
+	for range 2 {
+		puzzle, err := parser.FetchPuzzleWithOptions(file.Name(), parser.PuzzleOptions{Seed: 42})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var buf bytes.Buffer
+		if err = parser.ExportPuzzle(&buf, puzzle); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		exports = append(exports, buf.String())
+
+		imported, err := parser.ParseJSONPuzzle(buf.Bytes())
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(imported, puzzle) {
+			t.Errorf("imported puzzle is not equal exported one. expected: %v, got: %v", puzzle, imported)
+		}
+	}
+	if exports[0] != exports[1] {
+		t.Error("puzzles exported with the same seed are different")
+	}
+}
+
+// ExportYAMLPuzzle
+func TestExportYAMLPuzzle(t *testing.T) {
+	puzzle, err := parser.ParseJSONPuzzle([]byte(`{
+		"title": "TEST",
+		"description": ["TEST LINE 1"],
+		"tests": [
+			{ "name": "FIRST", "streams": [{ "type": "INPUT", "name": "IN", "position": 0, "values": [1, -2] }] },
+			{ "name": "SECOND", "streams": [{ "type": "INPUT", "name": "IN", "position": 0, "values": [] }] }
+		],
+		"width": 2,
+		"height": 1,
+		"layout": ["COMPUTE", "DAMAGED"],
+		"connections": [{ "from": 0, "from_port": "RIGHT", "to": 1, "to_port": "LEFT", "one_way": true }]
+	}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := parser.ExportYAMLPuzzle(&buf, puzzle); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	imported, err := parser.ParseYAMLPuzzle(buf.Bytes())
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(imported, puzzle) {
+		t.Errorf("imported puzzle is not equal exported one. expected: %v, got: %v", puzzle, imported)
+	}
+}
+
+/* UTILS */
+func SetupJSON(t *testing.T, content string) string {
+	return setupPuzzleFile(t, "puzzle.json", content)
+}
+
+func SetupYAML(t *testing.T, content string) string {
+	return setupPuzzleFile(t, "puzzle.yaml", content)
+}
+
+func setupPuzzleFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yuin/gopher-lua"
+)
+
+const (
+	DefaultTimeout         = 2 * time.Second
+	DefaultMaxInstructions = 50_000_000
+	DefaultMaxMemory       = 64 << 20
+
+	// measuring the memory of a state walks every value it holds, so it is
+	// only done periodically
+	memoryCheckInterval = 100_000
+
+	// estimated sizes of a value slot and of the header of a table, function
+	// or userdata
+	valueSize  = 16
+	objectSize = 64
+)
+
+var (
+	ErrTimeout          = errors.New("puzzle script timed out")
+	ErrInstructionLimit = errors.New("puzzle script exceeded instruction limit")
+	ErrMemoryLimit      = errors.New("puzzle script exceeded memory limit")
+)
+
+// budget is a context limiting a single run of a puzzle script in L. The Lua
+// VM checks Done before every instruction, which is used to count them and to
+// measure the memory held by L from time to time.
+type budget struct {
+	context.Context
+	L               *lua.LState
+	cancel          context.CancelCauseFunc
+	instructions    int
+	maxInstructions int
+	maxMemory       uint64
+}
+
+func newBudget(L *lua.LState, opts PuzzleOptions) (*budget, context.CancelFunc) {
+	opts = opts.withDefaults()
+	parent, cancel := context.WithCancelCause(context.Background())
+	ctx, stop := context.WithTimeoutCause(parent, opts.Timeout, ErrTimeout)
+
+	b := &budget{
+		Context:         ctx,
+		L:               L,
+		cancel:          cancel,
+		maxInstructions: opts.MaxInstructions,
+		maxMemory:       opts.MaxMemory,
+	}
+	return b, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+func (b *budget) Done() <-chan struct{} {
+	b.instructions++
+	if b.instructions > b.maxInstructions {
+		b.cancel(ErrInstructionLimit)
+	} else if b.instructions%memoryCheckInterval == 0 && stateSize(b.L) > b.maxMemory {
+		b.cancel(ErrMemoryLimit)
+	}
+	return b.Context.Done()
+}
+
+func (b *budget) Err() error {
+	if b.Context.Err() == nil {
+		return nil
+	}
+	return context.Cause(b.Context)
+}
+
+// stateSize estimates the bytes held by the values L can reach: its globals,
+// its registry and the locals of the running functions. Unlike the heap of
+// the process it only counts what the script allocated, whatever else runs.
+func stateSize(L *lua.LState) uint64 {
+	size := uint64(0)
+	seen := make(map[lua.LValue]bool)
+	values := []lua.LValue{L.G.Global, L.G.Registry}
+	for level := 0; ; level++ {
+		dbg, ok := L.GetStack(level)
+		if !ok {
+			break
+		}
+		for n := 1; ; n++ {
+			name, value := L.GetLocal(dbg, n)
+			if name == "" {
+				break
+			}
+			values = append(values, value)
+		}
+	}
+
+	for len(values) > 0 {
+		value := values[len(values)-1]
+		values = values[:len(values)-1]
+		switch v := value.(type) {
+		case lua.LString:
+			size += valueSize + uint64(len(v))
+			continue
+		case *lua.LTable, *lua.LFunction, *lua.LUserData:
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			size += objectSize
+		}
+
+		switch v := value.(type) {
+		case *lua.LTable:
+			values = append(values, v.Metatable)
+			v.ForEach(func(key lua.LValue, value lua.LValue) {
+				size += 2 * valueSize
+				values = append(values, key, value)
+			})
+		case *lua.LFunction:
+			values = append(values, v.Env)
+			for _, upvalue := range v.Upvalues {
+				size += valueSize
+				values = append(values, upvalue.Value())
+			}
+		case *lua.LUserData:
+			values = append(values, v.Metatable)
+		}
+	}
+	return size
+}
+
+func (opts PuzzleOptions) withDefaults() PuzzleOptions {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxInstructions <= 0 {
+		opts.MaxInstructions = DefaultMaxInstructions
+	}
+	if opts.MaxMemory == 0 {
+		opts.MaxMemory = DefaultMaxMemory
+	}
+	return opts
+}
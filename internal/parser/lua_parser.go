@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"math/rand"
+	"os"
+	"time"
 
 	"github.com/yuin/gopher-lua"
 
@@ -10,14 +14,71 @@ import (
 	"github.com/FranChesK0/tis-100/internal/types"
 )
 
-// TODO: use goroutines to call all fetch functions
-// TODO: refactor FetchPuzzle function
+// PuzzleOptions control how puzzle scripts are run. Scripts are untrusted by
+// default and only get the base, string, table and math libraries, so they
+// can not touch files or run commands. Trusted scripts get every library.
+//
+// Loading a puzzle and every call of its Validate function are limited by
+// Timeout, MaxInstructions and MaxMemory, zero values mean the defaults.
+// MaxMemory bounds the estimated size of the values held by the script.
+// Seed makes math.random return the same values on every load, zero picks a
+// new seed each time.
+type PuzzleOptions struct {
+	Trusted         bool
+	Timeout         time.Duration
+	MaxInstructions int
+	MaxMemory       uint64
+	Seed            int64
+}
+
+var safeLibs = []struct {
+	name string
+	fn   lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.StringLibName, lua.OpenString},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// functions of the base library that reach the file system or other modules
+var unsafeBaseFunctions = []string{"dofile", "loadfile", "require", "module"}
+
 func FetchPuzzle(fileName string) (*types.Puzzle, error) {
-	L := lua.NewState()
-	defer L.Close()
-	if err := L.DoFile(fileName); err != nil {
+	return FetchPuzzleWithOptions(fileName, PuzzleOptions{})
+}
+
+func FetchPuzzleWithOptions(fileName string, opts PuzzleOptions) (*types.Puzzle, error) {
+	source, err := os.ReadFile(fileName)
+	if err != nil {
 		return &types.Puzzle{}, fmt.Errorf("unable to load lua script %s: %w", fileName, err)
 	}
+	return ParseLuaPuzzle(fileName, source, opts)
+}
+
+// TODO: use goroutines to call all fetch functions
+// TODO: refactor ParseLuaPuzzle function
+func ParseLuaPuzzle(name string, source []byte, opts PuzzleOptions) (*types.Puzzle, error) {
+	L := newLuaState(opts)
+	keepState := false
+	defer func() {
+		if !keepState {
+			L.Close()
+		}
+	}()
+	ctx, cancel := newBudget(L, opts)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	fn, err := L.Load(bytes.NewReader(source), name)
+	if err == nil {
+		L.Push(fn)
+		err = L.PCall(0, lua.MultRet, nil)
+	}
+	if err != nil {
+		return &types.Puzzle{}, fmt.Errorf("unable to load lua script %s: %w", name, scriptError(L, err))
+	}
 
 	title, err := fetchTitle(L)
 	if err != nil {
@@ -27,21 +88,101 @@ func FetchPuzzle(fileName string) (*types.Puzzle, error) {
 	if err != nil {
 		return &types.Puzzle{}, err
 	}
-	streams, err := fetchStreams(L)
+	width, height, err := fetchGrid(L)
+	if err != nil {
+		return &types.Puzzle{}, err
+	}
+	tests, err := fetchTests(L, width)
 	if err != nil {
 		return &types.Puzzle{}, err
 	}
-	layout, err := fetchLayout(L)
+	var streams []types.Stream
+	if tests != nil && L.GetGlobal("GetStreams") == lua.LNil {
+		streams = tests[0].Streams
+	} else if streams, err = fetchStreams(L, width); err != nil {
+		return &types.Puzzle{}, err
+	}
+	layout, err := fetchLayout(L, width*height)
+	if err != nil {
+		return &types.Puzzle{}, err
+	}
+	connections, err := fetchConnections(L)
 	if err != nil {
 		return &types.Puzzle{}, err
 	}
 
-	return &types.Puzzle{
+	puzzle := &types.Puzzle{
 		Title:       title,
 		Description: description,
 		Streams:     streams,
+		Tests:       tests,
+		Width:       width,
+		Height:      height,
 		Layout:      layout,
-	}, nil
+		Connections: connections,
+	}
+	if err = checkWiring(puzzle); err != nil {
+		return &types.Puzzle{}, err
+	}
+	// the state stays open for the validator to be called while verifying
+	if fn, ok := L.GetGlobal("Validate").(*lua.LFunction); ok {
+		puzzle.Validator = &luaValidator{L: L, fn: fn, opts: opts}
+		keepState = true
+	}
+	return puzzle, nil
+}
+
+func newLuaState(opts PuzzleOptions) *lua.LState {
+	var L *lua.LState
+	if opts.Trusted {
+		L = lua.NewState()
+	} else {
+		L = lua.NewState(lua.Options{SkipOpenLibs: true})
+		for _, lib := range safeLibs {
+			L.Push(L.NewFunction(lib.fn))
+			L.Push(lua.LString(lib.name))
+			L.Call(1, 0)
+		}
+		for _, name := range unsafeBaseFunctions {
+			L.SetGlobal(name, lua.LNil)
+		}
+	}
+	setRandom(L, opts.Seed)
+	return L
+}
+
+// setRandom replaces math.random and math.randomseed, which use the global
+// generator of Go, with a generator owned by the state.
+func setRandom(L *lua.LState, seed int64) {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	mathTable := L.GetGlobal(lua.MathLibName).(*lua.LTable)
+	mathTable.RawSetString("random", L.NewFunction(func(L *lua.LState) int {
+		switch L.GetTop() {
+		case 0:
+			L.Push(lua.LNumber(r.Float64()))
+		case 1:
+			n := L.CheckInt(1)
+			if n < 1 {
+				L.ArgError(1, "interval is empty")
+			}
+			L.Push(lua.LNumber(r.Intn(n) + 1))
+		default:
+			low, high := L.CheckInt(1), L.CheckInt(2)
+			if low > high {
+				L.ArgError(2, "interval is empty")
+			}
+			L.Push(lua.LNumber(r.Intn(high-low+1) + low))
+		}
+		return 1
+	}))
+	mathTable.RawSetString("randomseed", L.NewFunction(func(L *lua.LState) int {
+		r.Seed(L.CheckInt64(1))
+		return 0
+	}))
 }
 
 func runLuaFunction(L *lua.LState, functionName string) (lua.LValue, error) {
@@ -50,13 +191,22 @@ func runLuaFunction(L *lua.LState, functionName string) (lua.LValue, error) {
 		NRet:    1,
 		Protect: true,
 	}); err != nil {
-		return nil, fmt.Errorf("error while calling %s function: %w", functionName, err)
+		return nil, fmt.Errorf("error while calling %s function: %w", functionName, scriptError(L, err))
 	}
 	value := L.Get(-1)
 	L.Pop(1)
 	return value, nil
 }
 
+// scriptError replaces an error raised by the VM with the reason the budget of
+// the script ran out, if it did.
+func scriptError(L *lua.LState, err error) error {
+	if ctx := L.Context(); ctx != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
 func fetchTitle(L *lua.LState) (string, error) {
 	runResult, err := runLuaFunction(L, "GetTitle")
 	if err != nil {
@@ -98,7 +248,45 @@ func fetchDescription(L *lua.LState) ([]string, error) {
 	return desc, nil
 }
 
-func fetchStreams(L *lua.LState) ([]types.Stream, error) {
+// fetchGrid returns the size of the grid declared by the optional GetGrid
+// function as { width, height }, which defaults to the original 4x3.
+func fetchGrid(L *lua.LState) (int, int, error) {
+	if L.GetGlobal("GetGrid") == lua.LNil {
+		return constants.DefaultGridWidth, constants.DefaultGridHeight, nil
+	}
+	runResult, err := runLuaFunction(L, "GetGrid")
+	if err != nil {
+		return 0, 0, err
+	}
+	gridTable, ok := runResult.(*lua.LTable)
+	if !ok || gridTable.Len() != 2 {
+		return 0, 0, errors.New("grid is not an array of width and height")
+	}
+	width, ok := gridTable.RawGetInt(1).(lua.LNumber)
+	if !ok {
+		return 0, 0, errors.New("grid width is not a number")
+	}
+	height, ok := gridTable.RawGetInt(2).(lua.LNumber)
+	if !ok {
+		return 0, 0, errors.New("grid height is not a number")
+	}
+	if err = checkGrid(int(width), int(height)); err != nil {
+		return 0, 0, err
+	}
+	return int(width), int(height), nil
+}
+
+func checkGrid(width int, height int) error {
+	if width < 1 || width > constants.MaxGridWidth {
+		return fmt.Errorf("grid width is not in range from 1 to %d", constants.MaxGridWidth)
+	}
+	if height < 1 || height > constants.MaxGridHeight {
+		return fmt.Errorf("grid height is not in range from 1 to %d", constants.MaxGridHeight)
+	}
+	return nil
+}
+
+func fetchStreams(L *lua.LState, width int) ([]types.Stream, error) {
 	runResult, err := runLuaFunction(L, "GetStreams")
 	if err != nil {
 		return nil, err
@@ -107,7 +295,70 @@ func fetchStreams(L *lua.LState) ([]types.Stream, error) {
 	if !ok {
 		return nil, errors.New("streams is not an array")
 	}
+	return parseStreams(streamsTable, width)
+}
+
+func fetchTests(L *lua.LState, width int) ([]types.Test, error) {
+	if L.GetGlobal("GetTests") == lua.LNil {
+		return nil, nil
+	}
+	runResult, err := runLuaFunction(L, "GetTests")
+	if err != nil {
+		return nil, err
+	}
+	testsTable, ok := runResult.(*lua.LTable)
+	if !ok {
+		return nil, errors.New("tests is not an array")
+	}
+	if testsTable.Len() == 0 {
+		return nil, errors.New("tests array is empty")
+	}
+
+	tests := make([]types.Test, 0, testsTable.Len())
+	testsTable.ForEach(func(_, value lua.LValue) {
+		if err != nil {
+			return
+		}
+		testTable, ok := value.(*lua.LTable)
+		if !ok {
+			err = errors.New("test is not an array")
+			return
+		}
+		if testTable.Len() != 2 {
+			err = fmt.Errorf("wrong test arguments number: expected 2, got %d", testTable.Len())
+			return
+		}
+
+		nameValue, ok := testTable.RawGetInt(1).(lua.LString)
+		if !ok {
+			err = errors.New("first value of test is not a string")
+			return
+		}
+		streamsTable, ok := testTable.RawGetInt(2).(*lua.LTable)
+		if !ok {
+			err = errors.New("second value of test is not an array")
+			return
+		}
+		streams, streamsErr := parseStreams(streamsTable, width)
+		if streamsErr != nil {
+			err = fmt.Errorf("test %s: %w", nameValue.String(), streamsErr)
+			return
+		}
+
+		tests = append(tests, types.Test{
+			Name:    nameValue.String(),
+			Streams: streams,
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return tests, nil
+}
 
+func parseStreams(streamsTable *lua.LTable, width int) ([]types.Stream, error) {
+	var err error
 	streams := make([]types.Stream, 0, streamsTable.Len())
 	streamsTable.ForEach(func(_, value lua.LValue) {
 		if err != nil {
@@ -138,8 +389,8 @@ func fetchStreams(L *lua.LState) ([]types.Stream, error) {
 			err = errors.New("third value of stream is not a number")
 			return
 		}
-		if posValue < 0 || posValue >= constants.IOPositionsNumber {
-			err = fmt.Errorf("position is not in range from 0 to %d", constants.IOPositionsNumber-1)
+		if posValue < 0 || int(posValue) >= width {
+			err = fmt.Errorf("position is not in range from 0 to %d", width-1)
 			return
 		}
 		valuesTable, ok := streamTable.RawGetInt(4).(*lua.LTable)
@@ -191,7 +442,7 @@ func fetchStreams(L *lua.LState) ([]types.Stream, error) {
 	return streams, nil
 }
 
-func fetchLayout(L *lua.LState) ([]types.NodeType, error) {
+func fetchLayout(L *lua.LState, nodesNumber int) ([]types.NodeType, error) {
 	runResult, err := runLuaFunction(L, "GetLayout")
 	if err != nil {
 		return nil, err
@@ -200,10 +451,10 @@ func fetchLayout(L *lua.LState) ([]types.NodeType, error) {
 	if !ok {
 		return nil, errors.New("layout is not an array")
 	}
-	if layoutTable.Len() != constants.NodesNumber {
+	if layoutTable.Len() != nodesNumber {
 		return nil, fmt.Errorf(
 			"wrong nodes number: expected %d, got %d",
-			constants.NodesNumber,
+			nodesNumber,
 			layoutTable.Len(),
 		)
 	}
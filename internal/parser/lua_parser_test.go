@@ -1,11 +1,14 @@
 package parser_test
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/FranChesK0/tis-100/internal/constants"
 	"github.com/FranChesK0/tis-100/internal/parser"
@@ -43,6 +46,8 @@ func TestFetchPuzzleWithCorrectScript(t *testing.T) {
 				Values:   []int16{1, 2, 3},
 			},
 		},
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
 		Layout: []types.NodeType{
 			constants.COMPUTE,
 			constants.COMPUTE,
@@ -529,11 +534,414 @@ func TestFetchPuzzleWithWrongLayoutValues(t *testing.T) {
 	}
 }
 
+func TestFetchPuzzleWithTests(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{
+		"function GetTests()",
+		"return {",
+		"{ \"ZEROS\", { { STREAM_INPUT, \"IN.TEST\", 0, { 0, 0 } }, { STREAM_OUTPUT, \"OUT.TEST\", 0, { 0, 0 } } } },",
+		"{ \"LIMITS\", { { STREAM_INPUT, \"IN.TEST\", 0, { -999 } }, { STREAM_OUTPUT, \"OUT.TEST\", 0, { 999 } } } },",
+		"}",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_tests.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedTests := []types.Test{
+		{
+			Name: "ZEROS",
+			Streams: []types.Stream{
+				{Type: constants.INPUT, Name: "IN.TEST", Position: 0, Values: []int16{0, 0}},
+				{Type: constants.OUTPUT, Name: "OUT.TEST", Position: 0, Values: []int16{0, 0}},
+			},
+		},
+		{
+			Name: "LIMITS",
+			Streams: []types.Stream{
+				{Type: constants.INPUT, Name: "IN.TEST", Position: 0, Values: []int16{-999}},
+				{Type: constants.OUTPUT, Name: "OUT.TEST", Position: 0, Values: []int16{999}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(puzzle.Tests, expectedTests) {
+		t.Error("tests are not equal expected result")
+	}
+	if !reflect.DeepEqual(puzzle.Streams[0].Values, []int16{1, 2, 3}) {
+		t.Error("streams are not taken from GetStreams")
+	}
+}
+
+func TestFetchPuzzleWithTestsWithoutStreams(t *testing.T) {
+	script := NewScript()
+	script.GetStreams = []string{""}
+	script.GetTests = []string{
+		"function GetTests()",
+		"return { { \"ONLY\", { { STREAM_INPUT, \"IN.TEST\", 0, { 7 } } } } }",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_tests_without_streams.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(puzzle.Streams, puzzle.Tests[0].Streams) {
+		t.Error("streams are not taken from the first test")
+	}
+}
+
+func TestFetchPuzzleWithWrongTests(t *testing.T) {
+	cases := map[string]string{
+		"return 1":                    "tests is not an array",
+		"return {}":                   "tests array is empty",
+		"return { 1 }":                "test is not an array",
+		"return { { \"A\" } }":        "wrong test arguments number: expected 2, got 1",
+		"return { { 1, {} } }":        "first value of test is not a string",
+		"return { { \"A\", 1 } }":     "second value of test is not an array",
+		"return { { \"A\", { 1 } } }": "test A: stream is not an array",
+	}
+	for body, expectedErr := range cases {
+		script := NewScript()
+		script.GetTests = []string{"function GetTests()", body, "end"}
+		file, err := SetupLua(t, *script, "test_fetch_puzzle_with_wrong_tests.lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parser.FetchPuzzle(file.Name())
+		if err == nil {
+			t.Errorf("expected to occure error for %s", body)
+			continue
+		}
+		if err.Error() != expectedErr {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+		}
+	}
+}
+
+func TestFetchPuzzleWithValidator(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{
+		"function Validate(name, produced, expected)",
+		"if #produced ~= #expected then return false, name .. \" has wrong length\" end",
+		"for i = 1, #produced do",
+		"if produced[i] > expected[i] then return false, \"value is too big\" end",
+		"end",
+		"return true",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_validator.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if puzzle.Validator == nil {
+		t.Fatal("expected validator to be set")
+	}
+
+	cases := []struct {
+		produced []int16
+		passed   bool
+		message  string
+	}{
+		{[]int16{1, 1, 3}, true, ""},
+		{[]int16{1, 2}, false, "OUT.TEST has wrong length"},
+		{[]int16{1, 5, 3}, false, "value is too big"},
+	}
+	for _, c := range cases {
+		passed, message, err := puzzle.Validator.Validate("OUT.TEST", c.produced, []int16{1, 2, 3})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if passed != c.passed || message != c.message {
+			t.Errorf("wrong verdict for %v. expected: %t %q, got: %t %q", c.produced, c.passed, c.message, passed, message)
+		}
+	}
+}
+
+func TestFetchPuzzleWithWrongValidator(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{"function Validate()", "return 1", "end"}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_wrong_validator.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, err = puzzle.Validator.Validate("OUT.TEST", nil, nil)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "first value returned by Validate is not a boolean"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+func TestFetchPuzzleWithClosedValidator(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{"function Validate()", "return true", "end"}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_closed_validator.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = puzzle.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, err = puzzle.Validator.Validate("OUT.TEST", nil, nil)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "puzzle is closed"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+func TestFetchPuzzleWithUnsafeLibraries(t *testing.T) {
+	calls := []string{"os.execute(\"true\")", "io.open(\"test\")", "dofile(\"test\")", "require(\"os\")"}
+	for _, call := range calls {
+		script := NewScript()
+		script.GetTitle = []string{"function GetTitle()", call, "return \"TEST\"", "end"}
+		file, err := SetupLua(t, *script, "test_fetch_puzzle_with_unsafe_libraries.lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parser.FetchPuzzle(file.Name())
+		if err == nil {
+			t.Errorf("expected to occure error for %s", call)
+		}
+	}
+}
+
+func TestFetchPuzzleWithTrustedScript(t *testing.T) {
+	script := NewScript()
+	script.GetTitle = []string{"function GetTitle()", "return string.upper(\"test\") .. os.date(\"\")", "end"}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_trusted_script.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = parser.FetchPuzzle(file.Name()); err == nil {
+		t.Error("expected to occure error")
+	}
+	puzzle, err := parser.FetchPuzzleWithOptions(file.Name(), parser.PuzzleOptions{Trusted: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if puzzle.Title != "TEST" {
+		t.Errorf("wrong title. expected: TEST, got: %s", puzzle.Title)
+	}
+}
+
+func TestFetchPuzzleWithExceededBudget(t *testing.T) {
+	cases := []struct {
+		loop        string
+		opts        parser.PuzzleOptions
+		expectedErr error
+	}{
+		{
+			"while true do end",
+			parser.PuzzleOptions{Timeout: 50 * time.Millisecond, MaxInstructions: math.MaxInt},
+			parser.ErrTimeout,
+		},
+		{
+			"while true do end",
+			parser.PuzzleOptions{MaxInstructions: 1000},
+			parser.ErrInstructionLimit,
+		},
+		{
+			"local t = {} while true do t[#t + 1] = string.rep(\"x\", 1000) .. #t end",
+			parser.PuzzleOptions{Timeout: time.Minute, MaxInstructions: math.MaxInt, MaxMemory: 1 << 20},
+			parser.ErrMemoryLimit,
+		},
+	}
+	for _, c := range cases {
+		script := NewScript()
+		script.GetStreams = append([]string{"function GetStreams()", c.loop}, script.GetStreams[1:]...)
+		file, err := SetupLua(t, *script, "test_fetch_puzzle_with_exceeded_budget.lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parser.FetchPuzzleWithOptions(file.Name(), c.opts)
+		if err == nil {
+			t.Fatal("expected to occure error")
+		}
+		if !errors.Is(err, c.expectedErr) {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestFetchPuzzleWithSlowValidator(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{"function Validate()", "while true do end", "end"}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_slow_validator.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzleWithOptions(file.Name(), parser.PuzzleOptions{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, _, err = puzzle.Validator.Validate("OUT.TEST", nil, nil)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "error while calling Validate function: puzzle script timed out"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+func TestFetchPuzzleWithGrid(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{"function GetGrid()", "return { 5, 2 }", "end"}
+	script.GetStreams = []string{
+		"function GetStreams()",
+		"return { { STREAM_INPUT, \"IN.TEST\", 4, { 1 } } }",
+		"end",
+	}
+	script.GetLayout = []string{
+		"function GetLayout()",
+		"local layout = {}",
+		"for i = 1, 10 do layout[i] = TILE_COMPUTE end",
+		"return layout",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_grid.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if puzzle.Width != 5 || puzzle.Height != 2 || len(puzzle.Layout) != 10 {
+		t.Errorf("wrong grid. expected: 5x2, got: %dx%d", puzzle.Width, puzzle.Height)
+	}
+}
+
+func TestFetchPuzzleWithWrongGrid(t *testing.T) {
+	cases := []struct {
+		grid        string
+		expectedErr string
+	}{
+		{"return 5", "grid is not an array of width and height"},
+		{"return { 0, 3 }", "grid width is not in range from 1 to 12"},
+		{"return { 4, 13 }", "grid height is not in range from 1 to 12"},
+		{"return { 2, 2 }", "position is not in range from 0 to 1"},
+	}
+	for _, c := range cases {
+		script := NewScript()
+		script.GetStreams[2] = "{ STREAM_INPUT, \"IN.TEST\", 3, { 1, 2, 3 } },"
+		script.GetTests = []string{"function GetGrid()", c.grid, "end"}
+		file, err := SetupLua(t, *script, "test_fetch_puzzle_with_wrong_grid.lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parser.FetchPuzzle(file.Name())
+		if err == nil {
+			t.Errorf("expected to occure error for %s", c.grid)
+			continue
+		}
+		if err.Error() != c.expectedErr {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+func TestFetchPuzzleWithConnections(t *testing.T) {
+	script := NewScript()
+	script.GetTests = []string{
+		"function GetConnections()",
+		"return { { 0, \"DOWN\", 8, \"UP\" }, { 3, \"RIGHT\", 0, \"LEFT\", true } }",
+		"end",
+	}
+	file, err := SetupLua(t, *script, "test_fetch_puzzle_with_connections.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	puzzle, err := parser.FetchPuzzle(file.Name())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedConnections := []types.Connection{
+		{From: 0, FromPort: constants.DOWN, To: 8, ToPort: constants.UP},
+		{From: 3, FromPort: constants.RIGHT, To: 0, ToPort: constants.LEFT, OneWay: true},
+	}
+	if !reflect.DeepEqual(puzzle.Connections, expectedConnections) {
+		t.Errorf("wrong connections. expected: %+v, got: %+v", expectedConnections, puzzle.Connections)
+	}
+}
+
+func TestFetchPuzzleWithWrongConnections(t *testing.T) {
+	cases := []struct {
+		connections string
+		expectedErr string
+	}{
+		{"return { { 0, \"DOWN\", 4 } }", "wrong connection arguments number: expected 4 or 5, got 3"},
+		{"return { { 0, \"SIDE\", 4, \"UP\" } }", "unknown port \"SIDE\""},
+		{"return { { 0, \"DOWN\", 4, \"UP\", 1 } }", "fifth value of connection is not a boolean"},
+		{"return { { 4, \"UP\", 8, \"UP\" }, { 5, \"UP\", 8, \"UP\" } }", "connection 2: port UP of node 8 is already connected"},
+		{"return { { 4, \"DOWN\", 0, \"UP\" } }", "port of stream IN.TEST is already connected"},
+	}
+	for _, c := range cases {
+		script := NewScript()
+		script.GetTests = []string{"function GetConnections()", c.connections, "end"}
+		file, err := SetupLua(t, *script, "test_fetch_puzzle_with_wrong_connections.lua")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = parser.FetchPuzzle(file.Name())
+		if err == nil {
+			t.Errorf("expected to occure error for %s", c.connections)
+			continue
+		}
+		if err.Error() != c.expectedErr {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
 // runLuaFunction -> covered in previous tests
 // fetchTitle -> covered in previous tests
 // fetchgetDescription -> covered in previous tests
 // fetchgetStreams -> covered in previous tests
 // fetchgetLayout -> covered in previous tests
+// fetchTests -> covered in previous tests
 
 /* BENCHMARKS */
 
@@ -559,6 +967,7 @@ type Script struct {
 	GetTitle       []string
 	GetDescription []string
 	GetStreams     []string
+	GetTests       []string
 	GetLayout      []string
 }
 
@@ -609,6 +1018,7 @@ func (s Script) Get() []string {
 	script := append(s.Beginning, s.GetTitle...)
 	script = append(script, s.GetDescription...)
 	script = append(script, s.GetStreams...)
+	script = append(script, s.GetTests...)
 	return append(script, s.GetLayout...)
 }
 
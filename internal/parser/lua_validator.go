@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/yuin/gopher-lua"
+)
+
+type luaValidator struct {
+	mu   sync.Mutex
+	L    *lua.LState
+	fn   *lua.LFunction
+	opts PuzzleOptions
+}
+
+func (v *luaValidator) Validate(streamName string, produced []int16, expected []int16) (bool, string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.L == nil {
+		return false, "", errors.New("puzzle is closed")
+	}
+
+	ctx, cancel := newBudget(v.L, v.opts)
+	defer cancel()
+	v.L.SetContext(ctx)
+	defer v.L.RemoveContext()
+
+	if err := v.L.CallByParam(lua.P{
+		Fn:      v.fn,
+		NRet:    2,
+		Protect: true,
+	}, lua.LString(streamName), valuesTable(v.L, produced), valuesTable(v.L, expected)); err != nil {
+		return false, "", fmt.Errorf("error while calling Validate function: %w", scriptError(v.L, err))
+	}
+	passed, message := v.L.Get(-2), v.L.Get(-1)
+	v.L.Pop(2)
+
+	ok, isBool := passed.(lua.LBool)
+	if !isBool {
+		return false, "", errors.New("first value returned by Validate is not a boolean")
+	}
+	if message == lua.LNil {
+		return bool(ok), "", nil
+	}
+	msg, isString := message.(lua.LString)
+	if !isString {
+		return false, "", errors.New("second value returned by Validate is not a string")
+	}
+	return bool(ok), msg.String(), nil
+}
+
+func (v *luaValidator) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.L != nil {
+		v.L.Close()
+		v.L = nil
+	}
+	return nil
+}
+
+func valuesTable(L *lua.LState, values []int16) *lua.LTable {
+	table := L.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LNumber(value))
+	}
+	return table
+}
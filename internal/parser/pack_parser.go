@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+const ManifestFileName = "manifest.json"
+
+type manifest struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Author      string            `json:"author"`
+	Description []string          `json:"description"`
+	Segments    []manifestSegment `json:"segments"`
+}
+
+type manifestSegment struct {
+	Name    string           `json:"name"`
+	Puzzles []manifestPuzzle `json:"puzzles"`
+}
+
+type manifestPuzzle struct {
+	File     string `json:"file"`
+	Solution string `json:"solution"`
+}
+
+// IsPack reports whether path is a zip file or a directory with a manifest.
+func IsPack(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return filepath.Ext(path) == ".zip"
+	}
+	_, err = os.Stat(filepath.Join(path, ManifestFileName))
+	return err == nil
+}
+
+// LoadPack reads a pack from a directory or a zip file with a manifest listing
+// its segments in order. As with FetchSegments, a puzzle or a reference
+// solution that fails to load is kept with its error set.
+func LoadPack(packPath string, opts PuzzleOptions) (*types.Pack, error) {
+	info, err := os.Stat(packPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open pack %s: %w", packPath, err)
+	}
+
+	var fsys fs.FS
+	if info.IsDir() {
+		fsys = os.DirFS(packPath)
+	} else {
+		reader, err := zip.OpenReader(packPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open pack %s: %w", packPath, err)
+		}
+		defer reader.Close()
+		fsys = reader
+	}
+
+	pack, err := parsePack(fsys, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pack %s: %w", packPath, err)
+	}
+	pack.Path = packPath
+	for i := range pack.Segments {
+		for j := range pack.Segments[i].Puzzles {
+			pack.Segments[i].Puzzles[j].Pack = packPath
+		}
+	}
+	return pack, nil
+}
+
+func parsePack(fsys fs.FS, opts PuzzleOptions) (*types.Pack, error) {
+	data, err := fs.ReadFile(fsys, ManifestFileName)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var m manifest
+	if err = decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFileName, err)
+	}
+
+	if m.Name == "" {
+		return nil, fmt.Errorf("%s: name is empty", ManifestFileName)
+	}
+	if len(m.Segments) == 0 {
+		return nil, fmt.Errorf("%s: pack has no segments", ManifestFileName)
+	}
+
+	pack := &types.Pack{
+		Name:        m.Name,
+		Version:     m.Version,
+		Author:      m.Author,
+		Description: m.Description,
+		Segments:    make([]types.Segment, 0, len(m.Segments)),
+	}
+	for _, s := range m.Segments {
+		if len(s.Puzzles) == 0 {
+			return nil, fmt.Errorf("%s: segment %s has no puzzles", ManifestFileName, s.Name)
+		}
+		segment := types.Segment{Name: s.Name, Puzzles: make([]types.PuzzleEntry, 0, len(s.Puzzles))}
+		for _, p := range s.Puzzles {
+			segment.Puzzles = append(segment.Puzzles, loadPackEntry(fsys, p, opts))
+		}
+		pack.Segments = append(pack.Segments, segment)
+	}
+	return pack, nil
+}
+
+func loadPackEntry(fsys fs.FS, p manifestPuzzle, opts PuzzleOptions) types.PuzzleEntry {
+	entry := types.PuzzleEntry{Path: p.File}
+	if !fs.ValidPath(p.File) {
+		entry.Err = fmt.Errorf("invalid puzzle path %q", p.File)
+		return entry
+	}
+	data, err := fs.ReadFile(fsys, p.File)
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	if entry.Puzzle, err = ParsePuzzle(p.File, data, opts); err != nil {
+		entry.Puzzle, entry.Err = nil, err
+		return entry
+	}
+
+	if p.Solution == "" {
+		return entry
+	}
+	if !fs.ValidPath(p.Solution) {
+		entry.Err = fmt.Errorf("invalid solution path %q", p.Solution)
+		return entry
+	}
+	file, err := fsys.Open(p.Solution)
+	if err != nil {
+		entry.Err = err
+		return entry
+	}
+	defer file.Close()
+	title := strings.ToUpper(strings.TrimSuffix(path.Base(p.Solution), ".tis"))
+	if entry.Solution, err = ParseCode(title, file); err != nil {
+		entry.Err = fmt.Errorf("error while reading file %s: %w", p.Solution, err)
+	}
+	return entry
+}
+
+// PackErrors lists every puzzle or solution of the pack that failed to load.
+func PackErrors(pack *types.Pack) error {
+	errs := make([]error, 0)
+	for _, segment := range pack.Segments {
+		for _, entry := range segment.Puzzles {
+			if entry.Err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Path, entry.Err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
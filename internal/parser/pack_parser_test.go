@@ -0,0 +1,172 @@
+package parser_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/parser"
+)
+
+/* TESTS */
+
+// LoadPack
+func TestLoadPackFromDirectory(t *testing.T) {
+	dir := t.TempDir()
+	SetupPack(t, dir)
+
+	pack, err := parser.LoadPack(dir, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	CheckPack(t, pack.Name, len(pack.Segments))
+
+	second := pack.Segments[1].Puzzles
+	if len(second) != 2 || second[0].Path != "second/b.json" || second[1].Path != "second/a.json" {
+		t.Fatalf("wrong order of puzzles: %+v", second)
+	}
+	if second[0].Solution == nil || second[0].Solution.Title != "B" {
+		t.Error("reference solution is not loaded")
+	}
+	if second[0].Pack != dir {
+		t.Errorf("wrong pack of puzzle. expected: %s, got: %s", dir, second[0].Pack)
+	}
+	if err = parser.PackErrors(pack); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestLoadPackFromZip(t *testing.T) {
+	dir := t.TempDir()
+	SetupPack(t, dir)
+	path := filepath.Join(t.TempDir(), "pack.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(file)
+	if err = w.AddFS(os.DirFS(dir)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if !parser.IsPack(path) {
+		t.Error("zip file is expected to be a pack")
+	}
+	pack, err := parser.LoadPack(path, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	CheckPack(t, pack.Name, len(pack.Segments))
+}
+
+func TestLoadPackWithMissingPuzzle(t *testing.T) {
+	dir := t.TempDir()
+	SetupPack(t, dir)
+	if err := os.Remove(filepath.Join(dir, "second", "a.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := parser.LoadPack(dir, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = parser.PackErrors(pack)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "second/a.json: open second/a.json"
+	if !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+func TestLoadPackWithWrongManifest(t *testing.T) {
+	cases := []struct {
+		manifest    string
+		expectedErr string
+	}{
+		{`{ "segments": [] }`, "manifest.json: name is empty"},
+		{`{ "name": "PACK" }`, "manifest.json: pack has no segments"},
+		{`{ "name": "PACK", "segments": [{ "name": "EMPTY" }] }`, "manifest.json: segment EMPTY has no puzzles"},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		WriteFile(t, filepath.Join(dir, parser.ManifestFileName), c.manifest)
+
+		_, err := parser.LoadPack(dir, parser.PuzzleOptions{})
+		if err == nil {
+			t.Errorf("expected to occure error for %s", c.manifest)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.expectedErr) {
+			t.Errorf("wrong error occurred. expected: %s, got: %s", c.expectedErr, err.Error())
+		}
+	}
+}
+
+// FetchSegments
+func TestFetchSegmentsWithPack(t *testing.T) {
+	dir := t.TempDir()
+	SetupPack(t, filepath.Join(dir, "pack"))
+
+	segments, err := parser.FetchSegments(dir, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(segments) != 2 || segments[0].Name != "PACK / FIRST" || segments[1].Name != "PACK / SECOND" {
+		t.Errorf("wrong segments: %+v", segments)
+	}
+}
+
+/* UTILS */
+func SetupPack(t *testing.T, dir string) {
+	puzzle := `{
+		"title": "%s",
+		"streams": [{ "type": "INPUT", "name": "IN", "position": 0, "values": [1] }],
+		"layout": [
+			"COMPUTE", "COMPUTE", "COMPUTE", "COMPUTE",
+			"COMPUTE", "COMPUTE", "COMPUTE", "COMPUTE",
+			"COMPUTE", "COMPUTE", "COMPUTE", "COMPUTE"
+		]
+	}`
+	WriteFile(t, filepath.Join(dir, parser.ManifestFileName), `{
+		"name": "PACK",
+		"version": "1.0",
+		"segments": [
+			{ "name": "FIRST", "puzzles": [{ "file": "first/a.json" }] },
+			{ "name": "SECOND", "puzzles": [
+				{ "file": "second/b.json", "solution": "second/b.tis" },
+				{ "file": "second/a.json" }
+			] }
+		]
+	}`)
+	WriteFile(t, filepath.Join(dir, "first", "a.json"), strings.Replace(puzzle, "%s", "FIRST A", 1))
+	WriteFile(t, filepath.Join(dir, "second", "a.json"), strings.Replace(puzzle, "%s", "SECOND A", 1))
+	WriteFile(t, filepath.Join(dir, "second", "b.json"), strings.Replace(puzzle, "%s", "SECOND B", 1))
+	WriteFile(t, filepath.Join(dir, "second", "b.tis"), "@1\nNOP\n")
+}
+
+func CheckPack(t *testing.T, name string, segments int) {
+	t.Helper()
+	if name != "PACK" {
+		t.Errorf("wrong pack name. expected: PACK, got: %s", name)
+	}
+	if segments != 2 {
+		t.Errorf("wrong segments number. expected: 2, got: %d", segments)
+	}
+}
+
+func WriteFile(t *testing.T, path string, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
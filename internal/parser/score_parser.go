@@ -0,0 +1,42 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+func SaveScores(dirPath string, scores map[string]types.Score) error {
+	filePath := filepath.Join(dirPath, constants.ScoresFileName)
+
+	data, err := json.MarshalIndent(scores, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to encode scores: %w", err)
+	}
+	if err = os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("error while writing data to file %s: %w", filePath, err)
+	}
+	return nil
+}
+
+func FetchScores(dirPath string) (map[string]types.Score, error) {
+	filePath := filepath.Join(dirPath, constants.ScoresFileName)
+
+	scores := make(map[string]types.Score)
+	data, err := os.ReadFile(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return scores, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to open file %s: %w", filePath, err)
+	}
+
+	if err = json.Unmarshal(data, &scores); err != nil {
+		return nil, fmt.Errorf("error while reading file %s: %w", filePath, err)
+	}
+	return scores, nil
+}
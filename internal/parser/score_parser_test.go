@@ -0,0 +1,124 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// SaveScores, FetchScores
+func TestSaveAndFetchScores(t *testing.T) {
+	dir, err := SetupDir(t, "test_scores")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores := map[string]types.Score{
+		"TEST": {Cycles: 42, Nodes: 3, Instructions: 6},
+	}
+	if err = parser.SaveScores(dir, scores); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fetched, err := parser.FetchScores(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(fetched, scores) {
+		t.Error("scores are not equal expected result")
+	}
+}
+
+func TestFetchScoresWithoutFile(t *testing.T) {
+	dir, err := SetupDir(t, "test_scores_without_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err := parser.FetchScores(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(scores) != 0 {
+		t.Error("expected empty scores")
+	}
+}
+
+func TestFetchScoresWithWrongFile(t *testing.T) {
+	dir, err := SetupDir(t, "test_scores_with_wrong_file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, constants.ScoresFileName), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = parser.FetchScores(dir)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "error while reading file"
+	if !strings.Contains(err.Error(), expectedErr) {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+// FetchSegments
+func TestFetchSegments(t *testing.T) {
+	dir, err := SetupDir(t, "test_segments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, "01 BASICS"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]string{
+		"root.lua":             NewScript().Get(),
+		"01 BASICS/b.lua":      NewScript().Get(),
+		"01 BASICS/a.lua":      NewScript().Get(),
+		"01 BASICS/broken.lua": {"function"},
+		"01 BASICS/notes.txt":  {"not a puzzle"},
+	}
+	for name, lines := range files {
+		content := strings.Join(lines, "\n")
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segments, err := parser.FetchSegments(dir, parser.PuzzleOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(segments) != 2 {
+		t.Fatalf("wrong segments number. expected: 2, got: %d", len(segments))
+	}
+	if segments[0].Name != "" || len(segments[0].Puzzles) != 1 {
+		t.Errorf("wrong root segment: %+v", segments[0])
+	}
+	basics := segments[1]
+	if basics.Name != "01 BASICS" || len(basics.Puzzles) != 3 {
+		t.Fatalf("wrong segment: %+v", basics)
+	}
+	names := []string{"a.lua", "b.lua", "broken.lua"}
+	for i, entry := range basics.Puzzles {
+		if filepath.Base(entry.Path) != names[i] {
+			t.Errorf("wrong puzzle order. expected: %s, got: %s", names[i], entry.Path)
+		}
+	}
+	if basics.Puzzles[0].Puzzle == nil || basics.Puzzles[0].Puzzle.Title != "TEST" {
+		t.Error("puzzle is not loaded")
+	}
+	if basics.Puzzles[2].Err == nil || basics.Puzzles[2].Puzzle != nil {
+		t.Error("expected broken puzzle to keep its error")
+	}
+}
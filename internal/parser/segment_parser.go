@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// FetchSegments collects puzzles from dirPath. Puzzles placed directly in
+// dirPath form an unnamed segment, every subdirectory forms a segment named
+// after it and every pack adds its own segments. A puzzle that fails to load
+// is kept with its error set.
+func FetchSegments(dirPath string, opts PuzzleOptions) ([]types.Segment, error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %s: %w", dirPath, err)
+	}
+
+	root := types.Segment{Puzzles: fetchEntries(dirPath, entries, opts)}
+	segments := make([]types.Segment, 0)
+	if len(root.Puzzles) > 0 {
+		segments = append(segments, root)
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		segmentPath := filepath.Join(dirPath, entry.Name())
+		if IsPack(segmentPath) {
+			pack, err := LoadPack(segmentPath, opts)
+			if err != nil {
+				segments = append(segments, types.Segment{
+					Name:    entry.Name(),
+					Puzzles: []types.PuzzleEntry{{Path: segmentPath, Err: err}},
+				})
+				continue
+			}
+			for _, segment := range pack.Segments {
+				segment.Name = pack.Name + " / " + segment.Name
+				segments = append(segments, segment)
+			}
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+		segmentEntries, err := os.ReadDir(segmentPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read directory %s: %w", segmentPath, err)
+		}
+
+		segment := types.Segment{
+			Name:    entry.Name(),
+			Puzzles: fetchEntries(segmentPath, segmentEntries, opts),
+		}
+		if len(segment.Puzzles) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments, nil
+}
+
+func fetchEntries(dirPath string, entries []os.DirEntry, opts PuzzleOptions) []types.PuzzleEntry {
+	puzzles := make([]types.PuzzleEntry, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !isPuzzleFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dirPath, entry.Name())
+		puzzle, err := LoadPuzzle(path, opts)
+		if err != nil {
+			puzzle = nil
+		}
+		puzzles = append(puzzles, types.PuzzleEntry{Path: path, Puzzle: puzzle, Err: err})
+	}
+
+	sort.SliceStable(puzzles, func(i, j int) bool {
+		return puzzles[i].Path < puzzles[j].Path
+	})
+	return puzzles
+}
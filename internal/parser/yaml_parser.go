@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+func FetchYAMLPuzzle(fileName string) (*types.Puzzle, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s: %w", fileName, err)
+	}
+	puzzle, err := ParseYAMLPuzzle(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid puzzle file %s: %w", fileName, err)
+	}
+	return puzzle, nil
+}
+
+// ParseYAMLPuzzle reads a puzzle written in YAML with the fields of the JSON
+// format.
+func ParseYAMLPuzzle(data []byte) (*types.Puzzle, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file puzzleFile
+	if err := decoder.Decode(&file); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("file is empty")
+		}
+		return nil, err
+	}
+	return file.puzzle()
+}
+
+// ExportYAMLPuzzle is ExportPuzzle writing the YAML format.
+func ExportYAMLPuzzle(w io.Writer, puzzle *types.Puzzle) error {
+	file, err := newPuzzleFile(puzzle)
+	if err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,239 @@
+package profile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// Profile tells where the cycles of a run went.
+type Profile struct {
+	Cycles int
+	Nodes  []NodeProfile
+	Loops  []Loop
+	Result string
+}
+
+// NodeProfile counts for every instruction of a node how many times it was
+// executed and how many cycles the cursor stayed on it, and how many cycles
+// the node spent in every mode. Lines are the lines of the instructions in
+// the node code.
+type NodeProfile struct {
+	Lines []int
+	Hits  []int
+	Time  []int
+	Modes [4]int
+}
+
+// Loop is a jump back from line End to line Start of a node, taken
+// Iterations times. Cycles are the cycles the node spent between both lines.
+// Code that just runs again from the top is not counted as a loop.
+type Loop struct {
+	Node       int
+	Start      int
+	End        int
+	Iterations int
+	Cycles     int
+}
+
+type edge struct {
+	node int
+	from int
+	to   int
+}
+
+// Run runs code on the grid of puzzle with the given streams until the
+// outputs are verified or maxCycles pass and profiles the run. An error of
+// the program ends the run and becomes its result.
+func Run(puzzle *types.Puzzle, streams []types.Stream, code types.ProgramCode, maxCycles int) (*Profile, error) {
+	p, err := runner.NewProgram(puzzle)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.LoadStreams(streams); err != nil {
+		return nil, err
+	}
+	if err := p.LoadCode(code); err != nil {
+		return nil, err
+	}
+
+	prof := &Profile{Nodes: make([]NodeProfile, len(p.Nodes)), Result: "cycle limit exceeded"}
+	index := make(map[*emu.Node]int)
+	for i, n := range p.Nodes {
+		index[n] = i
+		prof.Nodes[i] = NodeProfile{
+			Lines: n.LineNumbers,
+			Hits:  make([]int, len(n.Instructions)),
+			Time:  make([]int, len(n.Instructions)),
+		}
+	}
+
+	// a node whose value is read before its own tick runs two instructions
+	// in one cycle: the write that ends and the next one
+	cursors := make([]int, len(p.Nodes))
+	written := make([]bool, len(p.Nodes))
+	m := emu.Compile(p)
+	m.OnTransfer = func(from *emu.Node, _ *emu.Node, _ int16) {
+		if i, ok := index[from]; ok {
+			prof.Nodes[i].Hits[cursors[i]]++
+			written[i] = true
+		}
+	}
+
+	iterations := make(map[edge]int)
+	outputs := runner.OutputStreams(streams)
+	for prof.Cycles < maxCycles {
+		for i, n := range p.Nodes {
+			cursors[i] = cursor(n, int(n.CursorPosition))
+			written[i] = false
+		}
+
+		_, err := m.Tick()
+		m.Sync()
+		prof.Cycles++
+		for i, n := range p.Nodes {
+			np := &prof.Nodes[i]
+			if len(n.Instructions) == 0 {
+				np.Modes[emu.IDLE]++
+				continue
+			}
+			np.Time[cursors[i]]++
+
+			executed := make([]int, 0, 2)
+			if written[i] {
+				executed = append(executed, cursors[i])
+			}
+			switch {
+			case !n.Blocked && written[i]:
+				next := cursor(n, cursors[i]+1)
+				np.Hits[next]++
+				executed = append(executed, next)
+				np.Modes[emu.RUN]++
+			case !n.Blocked:
+				np.Hits[cursors[i]]++
+				executed = append(executed, cursors[i])
+				np.Modes[emu.RUN]++
+			case written[i]:
+				np.Modes[emu.WRTE]++
+			default:
+				np.Modes[n.Mode()]++
+			}
+
+			for j, from := range executed {
+				to := cursor(n, int(n.CursorPosition))
+				if j+1 < len(executed) {
+					to = executed[j+1]
+				}
+				if to <= from && isJump(n.Instructions[from].Operation) {
+					iterations[edge{i, from, to}]++
+				}
+			}
+		}
+
+		if err != nil {
+			prof.Result = err.Error()
+			break
+		}
+		verdict, reason, err := runner.Verify(puzzle.Validator, outputs, p.Outputs)
+		if err != nil {
+			return nil, err
+		}
+		if verdict == runner.PASSED {
+			prof.Result = "passed"
+			break
+		} else if verdict == runner.FAILED {
+			prof.Result = reason
+			break
+		}
+	}
+
+	prof.Loops = loops(prof.Nodes, iterations)
+	return prof, nil
+}
+
+func isJump(op emu.Operation) bool {
+	switch op {
+	case emu.JMP, emu.JEZ, emu.JNZ, emu.JGZ, emu.JLZ, emu.JRO:
+		return true
+	}
+	return false
+}
+
+func cursor(n *emu.Node, pos int) int {
+	if pos >= len(n.Instructions) {
+		return 0
+	}
+	return pos
+}
+
+// loops turns jumps back into loops sorted from the hottest one.
+func loops(nodes []NodeProfile, iterations map[edge]int) []Loop {
+	loops := make([]Loop, 0, len(iterations))
+	for e, count := range iterations {
+		np := nodes[e.node]
+		loop := Loop{Node: e.node, Start: np.Lines[e.to], End: np.Lines[e.from], Iterations: count}
+		for i := e.to; i <= e.from; i++ {
+			loop.Cycles += np.Time[i]
+		}
+		loops = append(loops, loop)
+	}
+	sort.Slice(loops, func(i, j int) bool {
+		a, b := loops[i], loops[j]
+		if a.Cycles != b.Cycles {
+			return a.Cycles > b.Cycles
+		}
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		return a.Start < b.Start
+	})
+	return loops
+}
+
+// LineHits returns the hits of every line of the node code, zero for lines
+// without an instruction.
+func (np NodeProfile) LineHits(lines int) []int {
+	hits := make([]int, lines)
+	for i, line := range np.Lines {
+		if line < lines {
+			hits[line] = np.Hits[i]
+		}
+	}
+	return hits
+}
+
+// Write prints the profile with the code of every node that has any and at
+// most the given number of loops.
+func Write(w io.Writer, prof *Profile, code types.ProgramCode, maxLoops int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%d CYCLES: %s\n", prof.Cycles, strings.ToUpper(prof.Result))
+	for i, np := range prof.Nodes {
+		if len(np.Hits) == 0 {
+			continue
+		}
+		fmt.Fprintf(
+			tw, "\nNODE %d: RUN %d / READ %d / WRTE %d / IDLE %d\n",
+			i, np.Modes[emu.RUN], np.Modes[emu.READ], np.Modes[emu.WRTE], np.Modes[emu.IDLE],
+		)
+		fmt.Fprintln(tw, "HITS\tCYCLES\tCODE")
+		lines := code.NodesCode[i]
+		for j, line := range np.Lines {
+			fmt.Fprintf(tw, "%d\t%d\t%s\n", np.Hits[j], np.Time[j], strings.TrimSpace(lines[line]))
+		}
+	}
+
+	if len(prof.Loops) > 0 && maxLoops > 0 {
+		fmt.Fprintln(tw, "\nHOTTEST LOOPS")
+		fmt.Fprintln(tw, "NODE\tLINES\tITERATIONS\tCYCLES")
+		for _, loop := range prof.Loops[:min(maxLoops, len(prof.Loops))] {
+			fmt.Fprintf(tw, "%d\t%d-%d\t%d\t%d\n", loop.Node, loop.Start+1, loop.End+1, loop.Iterations, loop.Cycles)
+		}
+	}
+	return tw.Flush()
+}
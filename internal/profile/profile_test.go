@@ -0,0 +1,95 @@
+package profile_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/profile"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Run
+func TestRun(t *testing.T) {
+	puzzle := newPuzzle()
+	prof, err := profile.Run(puzzle, puzzle.Streams, newCode(), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if prof.Result != "passed" {
+		t.Errorf("wrong result. expected: passed, got: %s", prof.Result)
+	}
+	node := prof.Nodes[0]
+	if expected := []int{1, 3, 3, 1}; !reflect.DeepEqual(node.Hits, expected) {
+		t.Errorf("wrong hits. expected: %v, got: %v", expected, node.Hits)
+	}
+	if expected := []int{0, 2, 3, 4}; !reflect.DeepEqual(node.Lines, expected) {
+		t.Errorf("wrong lines. expected: %v, got: %v", expected, node.Lines)
+	}
+	if total := node.Modes[emu.RUN] + node.Modes[emu.READ] + node.Modes[emu.WRTE]; total != prof.Cycles {
+		t.Errorf("wrong modes total. expected: %d, got: %d", prof.Cycles, total)
+	}
+	if node.Modes[emu.RUN] != 7 {
+		t.Errorf("wrong run cycles. expected: 7, got: %d", node.Modes[emu.RUN])
+	}
+	if prof.Nodes[1].Modes[emu.IDLE] != prof.Cycles {
+		t.Errorf("expected node without code to be idle, got: %v", prof.Nodes[1].Modes)
+	}
+
+	expectedLoops := []profile.Loop{{Node: 0, Start: 2, End: 3, Iterations: 2, Cycles: 6}}
+	if !reflect.DeepEqual(prof.Loops, expectedLoops) {
+		t.Errorf("wrong loops. expected: %+v, got: %+v", expectedLoops, prof.Loops)
+	}
+}
+
+// Write
+func TestWrite(t *testing.T) {
+	puzzle := newPuzzle()
+	code := newCode()
+	prof, err := profile.Run(puzzle, puzzle.Streams, code, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf strings.Builder
+	if err := profile.Write(&buf, prof, code, 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedParts := []string{
+		"NODE 0: RUN 7 /",
+		"HITS  CYCLES  CODE\n1     3       MOV UP, ACC\n3     3       L: SUB 1\n3     3       JGZ L\n",
+		"HOTTEST LOOPS\nNODE  LINES  ITERATIONS  CYCLES\n0     3-4    2           6\n",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(buf.String(), part) {
+			t.Errorf("expected profile to contain:\n%s\ngot:\n%s", part, buf.String())
+		}
+	}
+}
+
+/* UTILS */
+func newPuzzle() *types.Puzzle {
+	return &types.Puzzle{
+		Title: "TEST",
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{3}},
+			{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{0}},
+		},
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
+		Layout: make([]types.NodeType, constants.NodesNumber),
+	}
+}
+
+func newCode() types.ProgramCode {
+	nodesCode := make([][]string, constants.NodesNumber)
+	nodesCode[0] = []string{"MOV UP, ACC", "", "L: SUB 1", "JGZ L", "MOV ACC, DOWN"}
+	nodesCode[4] = []string{"MOV UP, DOWN"}
+	nodesCode[8] = []string{"MOV UP, DOWN"}
+	return types.ProgramCode{Title: "TEST", NodesCode: nodesCode}
+}
@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// Job is a single run of a solution against every test of a puzzle. Seed and
+// Solution only name the run, puzzles of other seeds are loaded by the caller.
+type Job struct {
+	Puzzle   *types.Puzzle
+	Seed     int64
+	Solution string
+	Code     types.ProgramCode
+}
+
+type JobResult struct {
+	Job
+	Passed  bool
+	Score   types.Score
+	Results []TestResult
+	Err     error
+}
+
+// RunBatch runs jobs on at most workers goroutines, or one per CPU if workers
+// is not positive. Every job builds its own programs, so jobs only share their
+// puzzles. Results are in the order of jobs whatever order they finish in.
+// Once ctx is done the running jobs stop, the rest are skipped with the error
+// of ctx as their Err and the error of ctx is returned along with the results.
+func RunBatch(ctx context.Context, jobs []Job, workers int, maxCycles int) ([]JobResult, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	results := make([]JobResult, len(jobs))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = runJob(ctx, jobs[i], maxCycles)
+			}
+		}()
+	}
+
+	sent := 0
+	for ; sent < len(jobs) && ctx.Err() == nil; sent++ {
+		indices <- sent
+	}
+	close(indices)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for i := sent; i < len(jobs); i++ {
+			results[i] = JobResult{Job: jobs[i], Err: err}
+		}
+		return results, err
+	}
+	return results, nil
+}
+
+func runJob(ctx context.Context, job Job, maxCycles int) JobResult {
+	res := JobResult{Job: job}
+	res.Results, res.Err = runTests(ctx, job.Puzzle, job.Code, maxCycles)
+	if res.Err == nil {
+		res.Passed, res.Score = Summarize(res.Results)
+	}
+	return res
+}
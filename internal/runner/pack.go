@@ -0,0 +1,32 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// CheckSolutions runs the reference solutions of the pack against every test
+// of their puzzles and reports the ones that do not pass.
+func CheckSolutions(pack *types.Pack, maxCycles int) error {
+	errs := make([]error, 0)
+	for _, segment := range pack.Segments {
+		for _, entry := range segment.Puzzles {
+			if entry.Err != nil || entry.Solution == nil {
+				continue
+			}
+			results, err := RunTests(entry.Puzzle, *entry.Solution, maxCycles)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", entry.Path, err))
+				continue
+			}
+			for _, res := range results {
+				if !res.Passed {
+					errs = append(errs, fmt.Errorf("%s: test %s failed: %s", entry.Path, res.Name, res.Reason))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
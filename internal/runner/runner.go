@@ -0,0 +1,248 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+const (
+	DefaultTestName = "DEFAULT"
+
+	// ctxCheckInterval is the number of cycles between checks whether a run
+	// is cancelled.
+	ctxCheckInterval = 1024
+)
+
+type Result struct {
+	Passed  bool
+	Reason  string
+	Score   types.Score
+	Outputs [][]int16
+}
+
+type TestResult struct {
+	Name string
+	Result
+}
+
+type Verdict uint8
+
+const (
+	PENDING Verdict = iota
+	PASSED
+	FAILED
+)
+
+func Run(puzzle *types.Puzzle, code types.ProgramCode, maxCycles int) (Result, error) {
+	return RunStreams(puzzle, puzzle.Streams, code, maxCycles)
+}
+
+// RunTests runs code against every test of the puzzle given by Tests.
+func RunTests(puzzle *types.Puzzle, code types.ProgramCode, maxCycles int) ([]TestResult, error) {
+	return runTests(context.Background(), puzzle, code, maxCycles)
+}
+
+func runTests(ctx context.Context, puzzle *types.Puzzle, code types.ProgramCode, maxCycles int) ([]TestResult, error) {
+	results := make([]TestResult, 0, len(Tests(puzzle)))
+	for _, test := range Tests(puzzle) {
+		res, err := runStreams(ctx, puzzle, test.Streams, code, maxCycles)
+		if err != nil {
+			return nil, fmt.Errorf("test %s: %w", test.Name, err)
+		}
+		results = append(results, TestResult{Name: test.Name, Result: res})
+	}
+	return results, nil
+}
+
+// Tests returns the tests of the puzzle. The visible streams are a test of
+// their own, as the TUI requires them to pass too, unless one of the tests is
+// made of them.
+func Tests(puzzle *types.Puzzle) []types.Test {
+	for _, test := range puzzle.Tests {
+		if reflect.DeepEqual(test.Streams, puzzle.Streams) {
+			return puzzle.Tests
+		}
+	}
+	return append([]types.Test{{Name: DefaultTestName, Streams: puzzle.Streams}}, puzzle.Tests...)
+}
+
+// Summarize reports whether every test passed and the score of the whole
+// run, which takes the cycles of the slowest test.
+func Summarize(results []TestResult) (bool, types.Score) {
+	passed := true
+	score := types.Score{}
+	for _, res := range results {
+		passed = passed && res.Passed
+		score.Cycles = max(score.Cycles, res.Score.Cycles)
+		score.Nodes = res.Score.Nodes
+		score.Instructions = res.Score.Instructions
+	}
+	return passed, score
+}
+
+// NewProgram creates a program with the grid of the puzzle, wired by its
+// connections if it has any. Damaged nodes of the puzzle refuse code.
+func NewProgram(puzzle *types.Puzzle) (*emu.Program, error) {
+	p := emu.NewGrid(puzzle.Width, puzzle.Height)
+	for i, nodeType := range puzzle.Layout {
+		if i < len(p.Nodes) && nodeType == constants.DAMAGED {
+			p.Nodes[i].Damaged = true
+		}
+	}
+	if puzzle.Connections != nil {
+		if err := p.Connect(puzzle.Connections); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// RunStreams runs code on the grid of puzzle with the given streams, which
+// are either the streams of the puzzle or of one of its tests.
+func RunStreams(
+	puzzle *types.Puzzle,
+	streams []types.Stream,
+	code types.ProgramCode,
+	maxCycles int,
+) (Result, error) {
+	return runStreams(context.Background(), puzzle, streams, code, maxCycles)
+}
+
+func runStreams(
+	ctx context.Context,
+	puzzle *types.Puzzle,
+	streams []types.Stream,
+	code types.ProgramCode,
+	maxCycles int,
+) (Result, error) {
+	p, err := NewProgram(puzzle)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := p.LoadStreams(streams); err != nil {
+		return Result{}, err
+	}
+	if err := p.LoadCode(code); err != nil {
+		return Result{}, err
+	}
+
+	m := emu.Compile(p)
+	outputs := OutputStreams(streams)
+	res := Result{Reason: "cycle limit exceeded"}
+	for cycles := 1; cycles <= maxCycles; cycles++ {
+		if cycles%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		if _, err := m.Tick(); err != nil {
+			return Result{}, err
+		}
+
+		verdict, reason, err := Verify(puzzle.Validator, outputs, p.Outputs)
+		if err != nil {
+			return Result{}, err
+		}
+		if verdict != PENDING {
+			res.Passed, res.Reason = verdict == PASSED, reason
+			res.Score = Measure(p, cycles)
+			break
+		}
+	}
+
+	res.Outputs = make([][]int16, 0, len(p.Outputs))
+	for _, output := range p.Outputs {
+		res.Outputs = append(res.Outputs, output.Values)
+	}
+	return res, nil
+}
+
+func OutputStreams(streams []types.Stream) []types.Stream {
+	outputs := make([]types.Stream, 0)
+	for _, stream := range streams {
+		if stream.Type == constants.OUTPUT {
+			outputs = append(outputs, stream)
+		}
+	}
+	return outputs
+}
+
+// Verify checks the produced values against the expected output streams.
+// Without a validator values are compared as they are produced, otherwise the
+// validator is called once every stream has produced enough values.
+func Verify(validator types.Validator, streams []types.Stream, outputs []*emu.Output) (Verdict, string, error) {
+	if validator == nil {
+		done, failed := CheckOutputs(streams, outputs)
+		if failed {
+			return FAILED, "validation failed", nil
+		} else if done {
+			return PASSED, "", nil
+		}
+		return PENDING, "", nil
+	}
+
+	for i, stream := range streams {
+		if len(outputs[i].Values) < len(stream.Values) {
+			return PENDING, "", nil
+		}
+	}
+	for i, stream := range streams {
+		passed, message, err := validator.Validate(stream.Name, outputs[i].Values, stream.Values)
+		if err != nil {
+			return FAILED, "", err
+		}
+		if !passed {
+			if message == "" {
+				message = "validation failed"
+			}
+			return FAILED, fmt.Sprintf("%s: %s", stream.Name, message), nil
+		}
+	}
+	return PASSED, "", nil
+}
+
+// CheckOutputs compares produced values with the expected output streams and
+// reports whether every stream is complete and whether any value is wrong.
+func CheckOutputs(streams []types.Stream, outputs []*emu.Output) (bool, bool) {
+	done := true
+	for i, stream := range streams {
+		produced := outputs[i].Values
+		for j, value := range produced {
+			if j >= len(stream.Values) || value != stream.Values[j] {
+				return false, true
+			}
+		}
+		done = done && len(produced) >= len(stream.Values)
+	}
+	return done, false
+}
+
+func Measure(p *emu.Program, cycles int) types.Score {
+	score := types.Score{Cycles: cycles}
+	for _, n := range p.Nodes {
+		if len(n.Instructions) > 0 {
+			score.Nodes++
+			score.Instructions += len(n.Instructions)
+		}
+	}
+	return score
+}
+
+// Best merges two scores keeping the best value of every metric, as each
+// metric is optimised separately.
+func Best(a, b types.Score) types.Score {
+	if a == (types.Score{}) {
+		return b
+	}
+	if b == (types.Score{}) {
+		return a
+	}
+	return types.Score{
+		Cycles:       min(a.Cycles, b.Cycles),
+		Nodes:        min(a.Nodes, b.Nodes),
+		Instructions: min(a.Instructions, b.Instructions),
+	}
+}
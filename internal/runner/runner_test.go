@@ -0,0 +1,431 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Run
+func TestRunWithCorrectSolution(t *testing.T) {
+	res, err := runner.Run(newPuzzle(), newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !res.Passed {
+		t.Errorf("expected solution to pass, got: %s", res.Reason)
+	}
+	expectedScore := types.Score{Cycles: 7, Nodes: 3, Instructions: 3}
+	if res.Score != expectedScore {
+		t.Errorf("wrong score. expected: %+v, got: %+v", expectedScore, res.Score)
+	}
+}
+
+func TestRunWithWrongSolution(t *testing.T) {
+	res, err := runner.Run(newPuzzle(), newCode("MOV UP, ACC", "ADD 1", "MOV ACC, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if res.Passed || res.Reason != "validation failed" {
+		t.Errorf("expected validation to fail, got: %+v", res)
+	}
+}
+
+func TestRunWithCycleLimit(t *testing.T) {
+	res, err := runner.Run(newPuzzle(), newCode("NOP"), 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if res.Passed || res.Reason != "cycle limit exceeded" {
+		t.Errorf("expected cycle limit to be exceeded, got: %+v", res)
+	}
+}
+
+func TestRunWithWrongCode(t *testing.T) {
+	_, err := runner.Run(newPuzzle(), newCode("MOV UP"), constants.MaxCycles)
+	if err == nil {
+		t.Error("expected to occure error")
+	}
+}
+
+func TestRunWithBiggerGrid(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Width, puzzle.Height = 5, 5
+	puzzle.Layout = make([]types.NodeType, 25)
+	for i := range puzzle.Streams {
+		puzzle.Streams[i].Position = 4
+	}
+	code := types.ProgramCode{NodesCode: make([][]string, 25)}
+	for i := 4; i < 25; i += 5 {
+		code.NodesCode[i] = []string{"MOV UP, DOWN"}
+	}
+
+	res, err := runner.Run(puzzle, code, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !res.Passed || res.Score.Nodes != 5 {
+		t.Errorf("expected solution to pass on 5 nodes, got: %+v", res)
+	}
+}
+
+func TestRunWithConnections(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Connections = []types.Connection{
+		{From: 0, FromPort: constants.DOWN, To: 8, ToPort: constants.UP},
+	}
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+	code.NodesCode[0] = []string{"MOV UP, DOWN"}
+	code.NodesCode[8] = []string{"MOV UP, DOWN"}
+
+	res, err := runner.Run(puzzle, code, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !res.Passed {
+		t.Errorf("expected solution to pass, got: %s", res.Reason)
+	}
+
+	puzzle.Connections[0] = types.Connection{From: 8, FromPort: constants.UP, To: 0, ToPort: constants.DOWN, OneWay: true}
+	res, err = runner.Run(puzzle, code, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Passed {
+		t.Error("values are not expected to go against one way connection")
+	}
+}
+
+func TestRunWithCodeInDamagedNode(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Layout[4] = constants.DAMAGED
+
+	_, err := runner.Run(puzzle, newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "node 4 is damaged and can not run code"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+
+	code := newCode("MOV UP, DOWN")
+	code.NodesCode[4] = []string{"# COMMENT", ""}
+	if _, err = runner.Run(puzzle, code, constants.MaxCycles); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// RunTests
+func TestRunTests(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Tests = []types.Test{
+		{Name: "SAME", Streams: puzzle.Streams},
+		{
+			Name: "DIFFERENT",
+			Streams: []types.Stream{
+				{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{1}},
+				{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{2}},
+			},
+		},
+	}
+
+	results, err := runner.RunTests(puzzle, newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("wrong results number. expected: 2, got: %d", len(results))
+	}
+	if results[0].Name != "SAME" || !results[0].Passed {
+		t.Errorf("expected test SAME to pass, got: %+v", results[0])
+	}
+	if results[1].Name != "DIFFERENT" || results[1].Passed {
+		t.Errorf("expected test DIFFERENT to fail, got: %+v", results[1])
+	}
+	if passed, _ := runner.Summarize(results); passed {
+		t.Error("expected summary to fail")
+	}
+}
+
+func TestRunTestsWithoutTests(t *testing.T) {
+	results, err := runner.RunTests(newPuzzle(), newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(results) != 1 || results[0].Name != runner.DefaultTestName {
+		t.Errorf("expected single default test, got: %+v", results)
+	}
+}
+
+func TestRunTestsWithHiddenTests(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Tests = []types.Test{
+		{
+			Name: "HIDDEN",
+			Streams: []types.Stream{
+				{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{-999, 0}},
+				{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{-999, 0}},
+			},
+		},
+	}
+	puzzle.Streams[1].Values = []int16{0, 0, 0}
+
+	results, err := runner.RunTests(puzzle, newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("wrong results number. expected: 2, got: %d", len(results))
+	}
+	if results[0].Name != runner.DefaultTestName || results[0].Passed {
+		t.Errorf("expected visible streams to fail, got: %+v", results[0])
+	}
+	if results[1].Name != "HIDDEN" || !results[1].Passed {
+		t.Errorf("expected test HIDDEN to pass, got: %+v", results[1])
+	}
+}
+
+// Verify
+func TestRunWithValidator(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Validator = anyOrderValidator{}
+
+	res, err := runner.Run(puzzle, newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !res.Passed {
+		t.Errorf("expected solution to pass, got: %s", res.Reason)
+	}
+
+	puzzle.Streams[1].Values = []int16{3, 1, 4}
+	res, err = runner.Run(puzzle, newCode("MOV UP, DOWN"), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedReason := "OUT: 4 is missing"
+	if res.Passed || res.Reason != expectedReason {
+		t.Errorf("wrong result. expected reason: %s, got: %+v", expectedReason, res)
+	}
+}
+
+// Best
+func TestBest(t *testing.T) {
+	a := types.Score{Cycles: 10, Nodes: 5, Instructions: 7}
+	b := types.Score{Cycles: 20, Nodes: 2, Instructions: 9}
+
+	expected := types.Score{Cycles: 10, Nodes: 2, Instructions: 7}
+	if best := runner.Best(a, b); best != expected {
+		t.Errorf("wrong best score. expected: %+v, got: %+v", expected, best)
+	}
+	if best := runner.Best(types.Score{}, b); best != b {
+		t.Errorf("wrong best score. expected: %+v, got: %+v", b, best)
+	}
+}
+
+// RunSandbox
+func TestRunSandbox(t *testing.T) {
+	nodesCode := make([][]string, constants.NodesNumber)
+	nodesCode[1] = []string{"MOV UP, ACC", "ADD ACC", "MOV ACC, DOWN"}
+	nodesCode[5] = []string{"MOV UP, DOWN"}
+	nodesCode[9] = []string{"MOV UP, RIGHT"}
+	nodesCode[10] = []string{"MOV LEFT, DOWN"}
+	code := types.ProgramCode{Title: runner.SandboxTitle, NodesCode: nodesCode}
+
+	var output strings.Builder
+	err := runner.RunSandbox(code, strings.NewReader("1 2\n-4\n"), &output, runner.DefaultIdleCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := "2\n4\n-8\n"
+	if output.String() != expected {
+		t.Errorf("wrong output. expected: %q, got: %q", expected, output.String())
+	}
+}
+
+func TestRunSandboxWithWrongValue(t *testing.T) {
+	code := types.ProgramCode{NodesCode: make([][]string, constants.NodesNumber)}
+
+	err := runner.RunSandbox(code, strings.NewReader("1000"), io.Discard, runner.DefaultIdleCycles)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "value is not in range from -999 to 999"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+// CheckSolutions
+func TestCheckSolutions(t *testing.T) {
+	correct, wrong := newCode("MOV UP, DOWN"), newCode("NOP")
+	pack := &types.Pack{
+		Name: "TEST",
+		Segments: []types.Segment{{
+			Name: "SEGMENT",
+			Puzzles: []types.PuzzleEntry{
+				{Path: "correct.lua", Puzzle: newPuzzle(), Solution: &correct},
+				{Path: "wrong.lua", Puzzle: newPuzzle(), Solution: &wrong},
+				{Path: "unsolved.lua", Puzzle: newPuzzle()},
+			},
+		}},
+	}
+
+	err := runner.CheckSolutions(pack, 100)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	expectedErr := "wrong.lua: test DEFAULT failed: cycle limit exceeded"
+	if err.Error() != expectedErr {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", expectedErr, err.Error())
+	}
+}
+
+// RunBatch
+func TestRunBatch(t *testing.T) {
+	correct, wrong := newCode("MOV UP, DOWN"), newCode("NOP")
+	jobs := make([]runner.Job, 0)
+	for seed := range int64(20) {
+		jobs = append(jobs,
+			runner.Job{Puzzle: newPuzzle(), Seed: seed, Solution: "correct", Code: correct},
+			runner.Job{Puzzle: newPuzzle(), Seed: seed, Solution: "wrong", Code: wrong},
+			runner.Job{Puzzle: newPuzzle(), Seed: seed, Solution: "broken", Code: newCode("MOV UP")},
+		)
+	}
+
+	results, err := runner.RunBatch(context.Background(), jobs, 4, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(results) != len(jobs) {
+		t.Fatalf("wrong results number. expected: %d, got: %d", len(jobs), len(results))
+	}
+	for i, res := range results {
+		if res.Solution != jobs[i].Solution || res.Seed != jobs[i].Seed {
+			t.Fatalf("wrong result order. expected: %s %d, got: %s %d", jobs[i].Solution, jobs[i].Seed, res.Solution, res.Seed)
+		}
+		switch res.Solution {
+		case "correct":
+			if !res.Passed || res.Score.Cycles != 7 {
+				t.Errorf("expected solution to pass in 7 cycles, got: %+v", res)
+			}
+		case "wrong":
+			if res.Passed || res.Err != nil {
+				t.Errorf("expected solution to fail, got: %+v", res)
+			}
+		case "broken":
+			if res.Err == nil {
+				t.Error("expected to occure error")
+			}
+		}
+	}
+}
+
+func TestRunBatchWithCodeInDamagedNode(t *testing.T) {
+	damaged := newPuzzle()
+	damaged.Layout[4] = constants.DAMAGED
+	jobs := []runner.Job{
+		{Puzzle: damaged, Solution: "damaged", Code: newCode("MOV UP, DOWN")},
+		{Puzzle: newPuzzle(), Solution: "intact", Code: newCode("MOV UP, DOWN")},
+	}
+
+	results, err := runner.RunBatch(context.Background(), jobs, 2, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if results[0].Err == nil || results[0].Passed {
+		t.Errorf("expected to occure error for code in damaged node, got: %+v", results[0])
+	}
+	if results[1].Err != nil || !results[1].Passed {
+		t.Errorf("expected solution to pass, got: %+v", results[1])
+	}
+}
+
+func TestRunBatchWithCancel(t *testing.T) {
+	jobs := make([]runner.Job, 0)
+	for i := range 10 {
+		jobs = append(jobs, runner.Job{Puzzle: newPuzzle(), Solution: fmt.Sprint(i), Code: newCode("NOP")})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	results, err := runner.RunBatch(ctx, jobs, 2, math.MaxInt)
+	if err == nil {
+		t.Fatal("expected to occure error")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wrong error occurred. expected: %s, got: %s", context.DeadlineExceeded, err)
+	}
+	if len(results) != len(jobs) {
+		t.Fatalf("wrong results number. expected: %d, got: %d", len(jobs), len(results))
+	}
+	for i, res := range results {
+		if res.Solution != jobs[i].Solution {
+			t.Errorf("wrong solution. expected: %s, got: %s", jobs[i].Solution, res.Solution)
+		}
+		if !errors.Is(res.Err, context.DeadlineExceeded) {
+			t.Errorf("wrong error of job %d. expected: %s, got: %v", i, context.DeadlineExceeded, res.Err)
+		}
+	}
+}
+
+/* UTILS */
+type anyOrderValidator struct{}
+
+func (anyOrderValidator) Validate(_ string, produced []int16, expected []int16) (bool, string, error) {
+	counts := make(map[int16]int)
+	for _, value := range produced {
+		counts[value]++
+	}
+	for _, value := range expected {
+		if counts[value] == 0 {
+			return false, fmt.Sprintf("%d is missing", value), nil
+		}
+		counts[value]--
+	}
+	return true, "", nil
+}
+
+func newPuzzle() *types.Puzzle {
+	layout := make([]types.NodeType, constants.NodesNumber)
+	return &types.Puzzle{
+		Title: "TEST",
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{1, 2, 3}},
+			{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{1, 2, 3}},
+		},
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
+		Layout: layout,
+	}
+}
+
+// newCode places the given lines in the left column of the grid.
+func newCode(lines ...string) types.ProgramCode {
+	nodesCode := make([][]string, constants.NodesNumber)
+	for i := 0; i < constants.NodesNumber; i += constants.IOPositionsNumber {
+		nodesCode[i] = lines
+	}
+	return types.ProgramCode{Title: "TEST", NodesCode: nodesCode}
+}
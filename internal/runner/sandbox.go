@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+const (
+	SandboxTitle      = "SANDBOX"
+	DefaultIdleCycles = 1000
+)
+
+func SandboxPuzzle() *types.Puzzle {
+	return &types.Puzzle{
+		Title: SandboxTitle,
+		Description: []string{
+			"> TYPE VALUES INTO IN AND",
+			"  WATCH THEM COME OUT OF OUT",
+		},
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN", Position: 1, Values: []int16{}},
+			{Type: constants.OUTPUT, Name: "OUT", Position: 2, Values: []int16{}},
+		},
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
+		Layout: make([]types.NodeType, constants.NodesNumber),
+	}
+}
+
+func NewSandbox(code types.ProgramCode) (*emu.Program, error) {
+	puzzle := SandboxPuzzle()
+	p, err := NewProgram(puzzle)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.LoadStreams(puzzle.Streams); err != nil {
+		return nil, err
+	}
+	if err := p.LoadCode(code); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func ParseValue(str string) (int16, error) {
+	value, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a number", str)
+	}
+	if value < constants.MinACC || value > constants.MaxACC {
+		return 0, fmt.Errorf("value is not in range from %d to %d", constants.MinACC, constants.MaxACC)
+	}
+	return int16(value), nil
+}
+
+// RunSandbox runs code in the sandbox without a TUI. Every whitespace
+// separated number read from input is sent to the input stream, and the
+// program then runs until it produces no output for idleCycles cycles before
+// the next value is read. Output values are written one per line.
+func RunSandbox(code types.ProgramCode, input io.Reader, output io.Writer, idleCycles int) error {
+	p, err := NewSandbox(code)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+	settle := func() error {
+		for idle := 0; idle < idleCycles; idle++ {
+			if _, err := p.Tick(); err != nil {
+				return err
+			}
+			values := p.Outputs[0].Values
+			for ; written < len(values); written++ {
+				idle = 0
+				if _, err := fmt.Fprintln(output, values[written]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(input)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		value, err := ParseValue(scanner.Text())
+		if err != nil {
+			return err
+		}
+		if err = p.PushInput(0, value); err != nil {
+			return err
+		}
+		if err = settle(); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("error while reading input: %w", err)
+	}
+
+	return settle()
+}
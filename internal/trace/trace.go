@@ -0,0 +1,264 @@
+package trace
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// Trace is a recorded run of a solution. Cycles[0] is the state before the
+// first cycle. Nodes of transfers are numbered as nodes of the grid, with the
+// streams following them in the order of Streams.
+type Trace struct {
+	Title   string
+	Width   int
+	Height  int
+	Layout  []types.NodeType
+	Streams []types.Stream
+	Code    types.ProgramCode
+	Cycles  []Cycle
+	Result  string
+}
+
+type Cycle struct {
+	Nodes     []NodeState
+	Transfers []Transfer
+}
+
+// NodeState is the state of a node after a cycle. Line is the line of the
+// node code under the cursor, or -1 if the node has no code.
+type NodeState struct {
+	Line int16
+	ACC  int16
+	BAK  int16
+	Mode emu.NodeMode
+}
+
+type Transfer struct {
+	From  int
+	To    int
+	Value int16
+}
+
+// Record runs code on the grid of puzzle with the given streams until the
+// outputs are verified or maxCycles pass, recording every cycle. An error of
+// the program ends the trace and becomes its result.
+func Record(puzzle *types.Puzzle, streams []types.Stream, code types.ProgramCode, maxCycles int) (*Trace, error) {
+	p, err := runner.NewProgram(puzzle)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.LoadStreams(streams); err != nil {
+		return nil, err
+	}
+	if err := p.LoadCode(code); err != nil {
+		return nil, err
+	}
+
+	index := make(map[*emu.Node]int)
+	for i, n := range p.Nodes {
+		index[n] = i
+	}
+	i := len(p.Nodes)
+	for list := p.NodeList; list != nil; list = list.Next {
+		index[list.Node] = i
+		i++
+	}
+
+	t := &Trace{
+		Title:   puzzle.Title,
+		Width:   puzzle.Width,
+		Height:  puzzle.Height,
+		Layout:  puzzle.Layout,
+		Streams: streams,
+		Code:    code,
+		Cycles:  []Cycle{{Nodes: nodeStates(p)}},
+		Result:  "cycle limit exceeded",
+	}
+
+	var transfers []Transfer
+	m := emu.Compile(p)
+	m.OnTransfer = func(from *emu.Node, to *emu.Node, value int16) {
+		transfers = append(transfers, Transfer{From: index[from], To: index[to], Value: value})
+	}
+	outputs := runner.OutputStreams(streams)
+	for range maxCycles {
+		transfers = nil
+		_, err := m.Tick()
+		m.Sync()
+		t.Cycles = append(t.Cycles, Cycle{Nodes: nodeStates(p), Transfers: transfers})
+		if err != nil {
+			t.Result = err.Error()
+			break
+		}
+
+		verdict, reason, err := runner.Verify(puzzle.Validator, outputs, p.Outputs)
+		if err != nil {
+			return nil, err
+		}
+		if verdict == runner.PASSED {
+			t.Result = "passed"
+			break
+		} else if verdict == runner.FAILED {
+			t.Result = reason
+			break
+		}
+	}
+	return t, nil
+}
+
+func nodeStates(p *emu.Program) []NodeState {
+	states := make([]NodeState, 0, len(p.Nodes))
+	for _, n := range p.Nodes {
+		state := NodeState{Line: -1, ACC: n.ACC, BAK: n.BAK, Mode: n.Mode()}
+		if len(n.Instructions) > 0 {
+			pos := int(n.CursorPosition)
+			if pos >= len(n.Instructions) {
+				pos = 0
+			}
+			state.Line = int16(n.LineNumbers[pos])
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// NodeName names a node of a transfer: the index of a grid node or the name
+// of a stream.
+func (t *Trace) NodeName(node int) string {
+	if node >= t.Width*t.Height {
+		return t.Streams[node-t.Width*t.Height].Name
+	}
+	return fmt.Sprint(node)
+}
+
+// Received returns the values received by the stream with the given index up
+// to the given cycle.
+func (t *Trace) Received(stream int, cycle int) []int16 {
+	node := t.Width*t.Height + stream
+	values := make([]int16, 0)
+	for _, c := range t.Cycles[:cycle+1] {
+		for _, transfer := range c.Transfers {
+			if transfer.To == node {
+				values = append(values, transfer.Value)
+			}
+		}
+	}
+	return values
+}
+
+func Write(w io.Writer, t *Trace) error {
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(t); err != nil {
+		return fmt.Errorf("unable to encode trace: %w", err)
+	}
+	return zw.Close()
+}
+
+func Read(r io.Reader) (*Trace, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read trace: %w", err)
+	}
+	defer zr.Close()
+
+	t := &Trace{}
+	if err := gob.NewDecoder(zr).Decode(t); err != nil {
+		return nil, fmt.Errorf("unable to decode trace: %w", err)
+	}
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// check makes sure every node and stream the trace refers to is on its grid,
+// so a truncated or edited trace fails here rather than when replayed.
+func (t *Trace) check() error {
+	if len(t.Cycles) == 0 {
+		return fmt.Errorf("trace has no cycles")
+	}
+	if t.Width <= 0 || t.Height <= 0 {
+		return fmt.Errorf("wrong grid size %dx%d", t.Width, t.Height)
+	}
+	size := t.Width * t.Height
+	if len(t.Layout) != size {
+		return fmt.Errorf("wrong layout size. expected: %d, got: %d", size, len(t.Layout))
+	}
+	if len(t.Code.NodesCode) > size {
+		return fmt.Errorf("code of %d nodes does not fit on the grid of %d nodes", len(t.Code.NodesCode), size)
+	}
+	for _, stream := range t.Streams {
+		if int(stream.Position) >= t.Width {
+			return fmt.Errorf("stream %s is out of grid", stream.Name)
+		}
+	}
+	for i, c := range t.Cycles {
+		if len(c.Nodes) != size {
+			return fmt.Errorf("cycle %d: wrong nodes number. expected: %d, got: %d", i, size, len(c.Nodes))
+		}
+		for _, transfer := range c.Transfers {
+			for _, node := range []int{transfer.From, transfer.To} {
+				if node < 0 || node >= size+len(t.Streams) {
+					return fmt.Errorf("cycle %d: transfer of unknown node %d", i, node)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func Save(file string, t *Trace) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("unable to create file with name %s: %w", file, err)
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("unable to close file with name %s: %w", file, closeErr)
+		}
+	}()
+	return Write(f, t)
+}
+
+func Fetch(file string) (*Trace, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file with name %s: %w", file, err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Dump writes the cycles of the trace from first to last inclusive as text.
+func Dump(w io.Writer, t *Trace, first int, last int) error {
+	last = min(last, len(t.Cycles)-1)
+	if first < 0 || first > last {
+		return fmt.Errorf("cycles are not in range from 0 to %d", len(t.Cycles)-1)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s %dx%d: %s\n", t.Title, t.Width, t.Height, strings.ToUpper(t.Result))
+	for i, c := range t.Cycles[first : last+1] {
+		fmt.Fprintf(tw, "\nCYCLE %d\n", first+i)
+		fmt.Fprintln(tw, "NODE\tMODE\tLINE\tACC\tBAK")
+		for node, state := range c.Nodes {
+			if state.Mode == emu.IDLE {
+				continue
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\n", node, state.Mode, state.Line+1, state.ACC, state.BAK)
+		}
+		for _, transfer := range c.Transfers {
+			fmt.Fprintf(tw, "%s -> %s: %d\n", t.NodeName(transfer.From), t.NodeName(transfer.To), transfer.Value)
+		}
+	}
+	return tw.Flush()
+}
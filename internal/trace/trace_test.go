@@ -0,0 +1,172 @@
+package trace_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/trace"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// Record
+func TestRecord(t *testing.T) {
+	puzzle := newPuzzle()
+	tr, err := trace.Record(puzzle, puzzle.Streams, newCode(), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if tr.Result != "passed" {
+		t.Errorf("wrong result. expected: passed, got: %s", tr.Result)
+	}
+	if len(tr.Cycles) != 6 {
+		t.Fatalf("wrong cycles number. expected: 6, got: %d", len(tr.Cycles))
+	}
+
+	expectedState := trace.NodeState{Line: 0, ACC: 0, BAK: 0, Mode: emu.READ}
+	if state := tr.Cycles[1].Nodes[4]; state != expectedState {
+		t.Errorf("wrong node state. expected: %+v, got: %+v", expectedState, state)
+	}
+	expectedTransfers := []trace.Transfer{{From: 12, To: 0, Value: 1}, {From: 0, To: 4, Value: 1}, {From: 4, To: 8, Value: 1}}
+	if !reflect.DeepEqual(tr.Cycles[1].Transfers, expectedTransfers) {
+		t.Errorf("wrong transfers. expected: %+v, got: %+v", expectedTransfers, tr.Cycles[1].Transfers)
+	}
+	if values := tr.Received(1, len(tr.Cycles)-1); !reflect.DeepEqual(values, []int16{1, 2}) {
+		t.Errorf("wrong received values. expected: [1 2], got: %v", values)
+	}
+}
+
+func TestRecordWithError(t *testing.T) {
+	puzzle := newPuzzle()
+	code := newCode()
+	code.NodesCode[0] = []string{"MOV UP, NIL"}
+
+	tr, err := trace.Record(puzzle, puzzle.Streams, code, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tr.Result != "unable to write" || len(tr.Cycles) != 2 {
+		t.Errorf("expected trace to end with error, got: %s after %d cycles", tr.Result, len(tr.Cycles))
+	}
+}
+
+// Write
+func TestWriteAndRead(t *testing.T) {
+	puzzle := newPuzzle()
+	expected, err := trace.Record(puzzle, puzzle.Streams, newCode(), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Write(&buf, expected); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	actual, err := trace.Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !reflect.DeepEqual(actual.Cycles, expected.Cycles) || actual.Result != expected.Result {
+		t.Errorf("wrong trace. expected: %+v, got: %+v", expected, actual)
+	}
+}
+
+func TestReadWithWrongData(t *testing.T) {
+	_, err := trace.Read(strings.NewReader("not a trace"))
+	if err == nil {
+		t.Error("expected to occure error")
+	}
+}
+
+func TestReadWithMalformedTrace(t *testing.T) {
+	tests := map[string]func(tr *trace.Trace){
+		"no cycles":          func(tr *trace.Trace) { tr.Cycles = nil },
+		"wrong grid":         func(tr *trace.Trace) { tr.Width = 0 },
+		"truncated layout":   func(tr *trace.Trace) { tr.Layout = tr.Layout[:5] },
+		"truncated nodes":    func(tr *trace.Trace) { tr.Cycles[2].Nodes = tr.Cycles[2].Nodes[:5] },
+		"transfer from":      func(tr *trace.Trace) { tr.Cycles[1].Transfers[0].From = 14 },
+		"transfer to":        func(tr *trace.Trace) { tr.Cycles[1].Transfers[0].To = -1 },
+		"stream out of grid": func(tr *trace.Trace) { tr.Streams[0].Position = 4 },
+	}
+	for name, change := range tests {
+		puzzle := newPuzzle()
+		tr, err := trace.Record(puzzle, puzzle.Streams, newCode(), constants.MaxCycles)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		change(tr)
+
+		var buf bytes.Buffer
+		if err := trace.Write(&buf, tr); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := trace.Read(&buf); err == nil {
+			t.Errorf("expected to occure error for %s", name)
+		}
+	}
+}
+
+// Dump
+func TestDump(t *testing.T) {
+	puzzle := newPuzzle()
+	tr, err := trace.Record(puzzle, puzzle.Streams, newCode(), constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf strings.Builder
+	if err := trace.Dump(&buf, tr, 4, 100); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := `TEST 4x3: PASSED
+
+CYCLE 4
+NODE  MODE  LINE  ACC  BAK
+0     READ  1     0    0
+4     READ  1     0    0
+8     READ  1     0    0
+8 -> OUT: 2
+
+CYCLE 5
+NODE  MODE  LINE  ACC  BAK
+0     READ  1     0    0
+4     READ  1     0    0
+8     READ  1     0    0
+`
+	if buf.String() != expected {
+		t.Errorf("wrong dump. expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	if err := trace.Dump(&buf, tr, 6, 10); err == nil {
+		t.Error("expected to occure error")
+	}
+}
+
+/* UTILS */
+func newPuzzle() *types.Puzzle {
+	return &types.Puzzle{
+		Title: "TEST",
+		Streams: []types.Stream{
+			{Type: constants.INPUT, Name: "IN", Position: 0, Values: []int16{1, 2}},
+			{Type: constants.OUTPUT, Name: "OUT", Position: 0, Values: []int16{1, 2}},
+		},
+		Width:  constants.DefaultGridWidth,
+		Height: constants.DefaultGridHeight,
+		Layout: make([]types.NodeType, constants.NodesNumber),
+	}
+}
+
+func newCode() types.ProgramCode {
+	nodesCode := make([][]string, constants.NodesNumber)
+	for i := 0; i < constants.NodesNumber; i += constants.IOPositionsNumber {
+		nodesCode[i] = []string{"MOV UP, DOWN"}
+	}
+	return types.ProgramCode{Title: "TEST", NodesCode: nodesCode}
+}
@@ -0,0 +1,164 @@
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Widths of VCD signals in bits.
+const (
+	registerWidth = 16
+	lineWidth     = 8
+	modeWidth     = 2
+)
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+type signal struct {
+	id    string
+	width int
+	value int64
+	set   bool
+}
+
+func (s *signal) format(value int64) string {
+	if s.width == 1 {
+		return fmt.Sprintf("%d%s", value, s.id)
+	}
+	mask := int64(1)<<s.width - 1
+	return fmt.Sprintf("b%b %s", value&mask, s.id)
+}
+
+type port struct {
+	from int
+	to   int
+}
+
+// WriteVCD writes the trace as a Value Change Dump with one time unit per
+// cycle. Every node with code has its ACC, BAK, line under the cursor and mode
+// as signals, and every pair of nodes that sent values has the last sent value
+// and a strobe that is high on cycles with a transfer.
+func WriteVCD(w io.Writer, t *Trace) error {
+	bw := bufio.NewWriter(w)
+	ids := 0
+	newSignal := func(width int) *signal {
+		s := &signal{width: width, id: vcdID(ids)}
+		ids++
+		return s
+	}
+
+	fmt.Fprintln(bw, "$version tis-100 $end")
+	fmt.Fprintf(bw, "$comment %s: %s $end\n", t.Title, t.Result)
+	fmt.Fprintln(bw, "$timescale 1 ns $end")
+	fmt.Fprintf(bw, "$scope module %s $end\n", vcdName(t.Title))
+
+	nodes := make([]int, 0)
+	registers := make(map[int][4]*signal)
+	for i, state := range t.Cycles[0].Nodes {
+		if state.Line < 0 {
+			continue
+		}
+		nodes = append(nodes, i)
+		sigs := [4]*signal{newSignal(registerWidth), newSignal(registerWidth), newSignal(lineWidth), newSignal(modeWidth)}
+		registers[i] = sigs
+		fmt.Fprintf(bw, "$scope module node_%d $end\n", i)
+		for j, name := range []string{"acc", "bak", "line", "mode"} {
+			fmt.Fprintf(bw, "$var reg %d %s %s $end\n", sigs[j].width, sigs[j].id, name)
+		}
+		fmt.Fprintln(bw, "$upscope $end")
+	}
+
+	ports := make([]port, 0)
+	seen := make(map[port]bool)
+	for _, c := range t.Cycles {
+		for _, transfer := range c.Transfers {
+			p := port{transfer.From, transfer.To}
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].from != ports[j].from {
+			return ports[i].from < ports[j].from
+		}
+		return ports[i].to < ports[j].to
+	})
+	values := make(map[port][2]*signal)
+	fmt.Fprintln(bw, "$scope module ports $end")
+	for _, p := range ports {
+		sigs := [2]*signal{newSignal(registerWidth), newSignal(1)}
+		values[p] = sigs
+		name := vcdName(fmt.Sprintf("%s_to_%s", t.NodeName(p.from), t.NodeName(p.to)))
+		fmt.Fprintf(bw, "$var reg %d %s %s $end\n", registerWidth, sigs[0].id, name)
+		fmt.Fprintf(bw, "$var wire 1 %s %s_valid $end\n", sigs[1].id, name)
+	}
+	fmt.Fprintln(bw, "$upscope $end")
+	fmt.Fprintln(bw, "$upscope $end")
+	fmt.Fprintln(bw, "$enddefinitions $end")
+
+	for cycle, c := range t.Cycles {
+		changes := make([]string, 0)
+		change := func(s *signal, value int64) {
+			if !s.set || s.value != value {
+				s.set, s.value = true, value
+				changes = append(changes, s.format(value))
+			}
+		}
+
+		for _, i := range nodes {
+			state := c.Nodes[i]
+			sigs := registers[i]
+			change(sigs[0], int64(state.ACC))
+			change(sigs[1], int64(state.BAK))
+			change(sigs[2], int64(state.Line))
+			change(sigs[3], int64(state.Mode))
+		}
+		sent := make(map[port]bool)
+		for _, transfer := range c.Transfers {
+			p := port{transfer.From, transfer.To}
+			sent[p] = true
+			change(values[p][0], int64(transfer.Value))
+		}
+		for _, p := range ports {
+			if !values[p][0].set {
+				change(values[p][0], 0)
+			}
+			strobe := int64(0)
+			if sent[p] {
+				strobe = 1
+			}
+			change(values[p][1], strobe)
+		}
+
+		fmt.Fprintf(bw, "#%d\n", cycle)
+		if cycle == 0 {
+			fmt.Fprintf(bw, "$dumpvars\n%s\n$end\n", strings.Join(changes, "\n"))
+		} else if len(changes) > 0 {
+			fmt.Fprintln(bw, strings.Join(changes, "\n"))
+		}
+	}
+	return bw.Flush()
+}
+
+// vcdIDChars are the characters of signal identifiers, printable ASCII
+// without $ that starts VCD keywords.
+const vcdIDChars = "!\"#%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~"
+
+// vcdID returns the short identifier of the signal with the given number.
+func vcdID(n int) string {
+	id := []byte{vcdIDChars[n%len(vcdIDChars)]}
+	for n /= len(vcdIDChars); n > 0; n /= len(vcdIDChars) {
+		id = append(id, vcdIDChars[n%len(vcdIDChars)])
+	}
+	return string(id)
+}
+
+func vcdName(name string) string {
+	return unsafeNameChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,44 @@
+package trace_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/trace"
+)
+
+/* TESTS */
+
+// WriteVCD
+func TestWriteVCD(t *testing.T) {
+	puzzle := newPuzzle()
+	puzzle.Streams[1].Values = []int16{-1, -2}
+	code := newCode()
+	code.NodesCode[0] = []string{"MOV UP, ACC", "NEG", "MOV ACC, DOWN"}
+	tr, err := trace.Record(puzzle, puzzle.Streams, code, constants.MaxCycles)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf strings.Builder
+	if err := trace.WriteVCD(&buf, tr); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	vcd := buf.String()
+
+	expectedParts := []string{
+		"$scope module node_0 $end\n$var reg 16 ! acc $end\n$var reg 16 \" bak $end\n$var reg 8 # line $end\n$var reg 2 % mode $end\n$upscope $end\n",
+		"$var reg 16 4 IN_to_0 $end\n$var wire 1 5 IN_to_0_valid $end\n",
+		"$enddefinitions $end\n#0\n$dumpvars\n",
+		"#1\nb1 !\nb1 #\nb10 )\nb10 -\nb1 4\n15\n#2\nb1111111111111111 !\nb10 #\n05\n",
+	}
+	for _, part := range expectedParts {
+		if !strings.Contains(vcd, part) {
+			t.Errorf("expected VCD to contain:\n%s\ngot:\n%s", part, vcd)
+		}
+	}
+	if strings.Count(vcd, "\n#") != len(tr.Cycles) {
+		t.Errorf("wrong number of time steps. expected: %d, got: %d", len(tr.Cycles), strings.Count(vcd, "\n#"))
+	}
+}
@@ -0,0 +1,256 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+const browserWidth = 40
+
+type clearErrMsg struct{}
+
+func clearErrorAfter(t time.Duration) tea.Cmd {
+	return tea.Tick(t, func(_ time.Time) tea.Msg { return clearErrMsg{} })
+}
+
+type browserItem struct {
+	segment int
+	puzzle  int
+}
+
+type browser struct {
+	segments  []types.Segment
+	items     []browserItem
+	scores    map[string]types.Score
+	scoresErr error
+	cursor    int
+	loading   bool
+	err       error
+}
+
+type segmentsLoadedMsg struct {
+	segments []types.Segment
+	err      error
+}
+
+type puzzleLoadedMsg struct {
+	path   string
+	puzzle *types.Puzzle
+	err    error
+}
+
+// newBrowser only lists the sandbox, puzzles are added once loadSegments is
+// done as their scripts may take a while to run.
+func newBrowser(saveDir string) browser {
+	b := browser{loading: true}
+	b.setSegments(nil)
+	b.refreshScores(saveDir)
+	return b
+}
+
+func loadSegments(puzzleDir string, opts parser.PuzzleOptions) tea.Cmd {
+	return func() tea.Msg {
+		segments, err := parser.FetchSegments(puzzleDir, opts)
+		return segmentsLoadedMsg{segments: segments, err: err}
+	}
+}
+
+func loadPuzzle(path string, opts parser.PuzzleOptions) tea.Cmd {
+	return func() tea.Msg {
+		puzzle, err := parser.LoadPuzzle(path, opts)
+		return puzzleLoadedMsg{path: path, puzzle: puzzle, err: err}
+	}
+}
+
+func (b *browser) setSegments(segments []types.Segment) {
+	sandbox := types.Segment{
+		Name:    runner.SandboxTitle,
+		Puzzles: []types.PuzzleEntry{{Puzzle: runner.SandboxPuzzle()}},
+	}
+	b.segments = append([]types.Segment{sandbox}, segments...)
+	b.items = nil
+	for i, segment := range b.segments {
+		for j := range segment.Puzzles {
+			b.items = append(b.items, browserItem{segment: i, puzzle: j})
+		}
+	}
+}
+
+// refreshScores reads the scores again. Puzzles are still listed when the
+// scores can not be read, just without them.
+func (b *browser) refreshScores(saveDir string) {
+	scores, err := parser.FetchScores(saveDir)
+	if err != nil {
+		scores = make(map[string]types.Score)
+	}
+	b.scores = scores
+	b.scoresErr = err
+}
+
+func (b browser) entry(item int) types.PuzzleEntry {
+	it := b.items[item]
+	return b.segments[it.segment].Puzzles[it.puzzle]
+}
+
+// entryPath returns the path a puzzle of the browser is opened with.
+func entryPath(entry types.PuzzleEntry) string {
+	if entry.Pack != "" {
+		return entry.Pack + ":" + entry.Path
+	}
+	return entry.Path
+}
+
+// scoreKey returns the key of the scores of the puzzle at path. Paths are
+// used rather than titles, which several puzzles may share, relative to the
+// puzzle directory so that scores stay when it is moved.
+func scoreKey(puzzleDir string, path string) string {
+	if rel, err := filepath.Rel(puzzleDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+func (m model) score(entry types.PuzzleEntry) (types.Score, bool) {
+	score, ok := m.browser.scores[scoreKey(m.config.PuzzleDir, entryPath(entry))]
+	return score, ok
+}
+
+func (m model) updateBrowser(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case clearErrMsg:
+		m.status = ""
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Up):
+			if m.browser.cursor > 0 {
+				m.browser.cursor--
+			}
+		case key.Matches(msg, m.keys.Down):
+			if m.browser.cursor < len(m.browser.items)-1 {
+				m.browser.cursor++
+			}
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		case key.Matches(msg, m.keys.Select):
+			if len(m.browser.items) == 0 {
+				return m, nil
+			}
+			entry := m.browser.entry(m.browser.cursor)
+			if entry.Err != nil {
+				m.status = entry.Err.Error()
+				return m, clearErrorAfter(statusTimeout)
+			}
+			if entry.Path == "" {
+				m.openSandbox(nil)
+				return m, nil
+			}
+			// puzzles of packs can not be read again by path
+			if entry.Pack != "" {
+				m.puzzlePath = entryPath(entry)
+				m.puzzle = entry.Puzzle
+				m.openEditors()
+				return m, nil
+			}
+			m.puzzlePath = entry.Path
+			m.puzzle = nil
+			return m, loadPuzzle(entry.Path, puzzleOptions(m.config))
+		}
+	}
+
+	return m, nil
+}
+
+func (m model) viewBrowser() string {
+	if m.browser.err != nil {
+		return m.styles.Error.Render("Error while fetching puzzles: " + m.browser.err.Error())
+	}
+	if len(m.browser.items) == 0 {
+		return "No puzzles found in " + m.config.PuzzleDir
+	}
+
+	lines := make([]string, 0)
+	selected := 0
+	prevSegment := -1
+	for i, item := range m.browser.items {
+		if item.segment != prevSegment {
+			name := m.browser.segments[item.segment].Name
+			if name == "" {
+				name = "PUZZLES"
+			}
+			lines = append(lines, m.styles.Title.Render(strings.ToUpper(name)))
+			prevSegment = item.segment
+		}
+
+		entry := m.browser.entry(i)
+		mark, title := "  ", entry.Path
+		if entry.Err != nil {
+			mark = m.styles.Error.Render("! ")
+		} else {
+			title = entry.Puzzle.Title
+			if _, ok := m.score(entry); ok {
+				mark = "✓ "
+			}
+		}
+
+		line := mark + title
+		if i == m.browser.cursor {
+			line = m.styles.Cursor.Render("> " + line)
+			selected = len(lines)
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if m.browser.loading {
+		lines = append(lines, "", m.styles.Status.Render("LOADING PUZZLES..."))
+	}
+	if m.browser.scoresErr != nil {
+		lines = append(lines, "", m.styles.Error.Render("Unable to read scores: "+m.browser.scoresErr.Error()))
+	}
+
+	height := m.height - 4
+	if height > 0 && len(lines) > height {
+		start := min(max(selected-height/2, 0), len(lines)-height)
+		lines = lines[start : start+height]
+	}
+
+	list := lipgloss.NewStyle().Width(browserWidth).Render(strings.Join(lines, "\n"))
+	return lipgloss.JoinVertical(
+		lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, list, m.viewPreview()),
+		m.viewStatus(),
+		m.help.View(m.keys),
+	)
+}
+
+func (m model) viewPreview() string {
+	entry := m.browser.entry(m.browser.cursor)
+	if entry.Err != nil {
+		return m.styles.Error.Render(entry.Err.Error())
+	}
+
+	lines := []string{m.styles.Title.Render(entry.Puzzle.Title), ""}
+	lines = append(lines, entry.Puzzle.Description...)
+	lines = append(lines, "")
+	if score, ok := m.score(entry); ok {
+		lines = append(lines, fmt.Sprintf(
+			"SOLVED: %d CYCLES / %d NODES / %d INSTR",
+			score.Cycles,
+			score.Nodes,
+			score.Instructions,
+		))
+	} else {
+		lines = append(lines, m.styles.Status.Render("UNSOLVED"))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,514 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/FranChesK0/tis-100/internal/analyzer"
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/profile"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+type nodeEditor struct {
+	damaged bool
+	lines   []string
+	row     int
+	col     int
+	errs    []*emu.AssemblyError
+	warns   []*emu.AssemblyError
+}
+
+func newNodeEditor(nodeType types.NodeType, code []string) nodeEditor {
+	e := nodeEditor{
+		damaged: nodeType == constants.DAMAGED,
+		lines:   []string{""},
+	}
+	if len(code) > 0 && !e.damaged {
+		e.lines = append([]string{}, code...)
+	}
+	e.check()
+	return e
+}
+
+func (e *nodeEditor) check() {
+	e.errs = emu.CheckCode(e.lines)
+}
+
+func (e nodeEditor) code() []string {
+	code := make([]string, 0, len(e.lines))
+	for _, line := range e.lines {
+		if strings.TrimSpace(line) != "" {
+			code = append(code, line)
+		}
+	}
+	return code
+}
+
+// lineError returns the error of a line, or its warning when it has no
+// error.
+func (e nodeEditor) lineError(row int) *emu.AssemblyError {
+	for _, diags := range [][]*emu.AssemblyError{e.errs, e.warns} {
+		for _, diag := range diags {
+			if diag.Line == row {
+				return diag
+			}
+		}
+	}
+	return nil
+}
+
+func (e *nodeEditor) update(msg tea.KeyMsg) {
+	line := e.lines[e.row]
+	switch msg.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		text := strings.ToUpper(string(msg.Runes))
+		for _, r := range text {
+			if r < ' ' || r > '~' {
+				return
+			}
+		}
+		if len(line)+len(text) > constants.MaxLineLength {
+			return
+		}
+		e.lines[e.row] = line[:e.col] + text + line[e.col:]
+		e.col += len(text)
+	case tea.KeyBackspace:
+		if e.col > 0 {
+			e.lines[e.row] = line[:e.col-1] + line[e.col:]
+			e.col--
+		} else if e.row > 0 && len(e.lines[e.row-1])+len(line) <= constants.MaxLineLength {
+			e.col = len(e.lines[e.row-1])
+			e.lines[e.row-1] += line
+			e.lines = append(e.lines[:e.row], e.lines[e.row+1:]...)
+			e.row--
+		}
+	case tea.KeyDelete:
+		if e.col < len(line) {
+			e.lines[e.row] = line[:e.col] + line[e.col+1:]
+		} else if e.row < len(e.lines)-1 && len(line)+len(e.lines[e.row+1]) <= constants.MaxLineLength {
+			e.lines[e.row] += e.lines[e.row+1]
+			e.lines = append(e.lines[:e.row+1], e.lines[e.row+2:]...)
+		}
+	case tea.KeyEnter:
+		if len(e.lines) >= constants.MaxNodeLines {
+			return
+		}
+		rest := line[e.col:]
+		e.lines[e.row] = line[:e.col]
+		e.lines = append(e.lines[:e.row+1], append([]string{rest}, e.lines[e.row+1:]...)...)
+		e.row++
+		e.col = 0
+	case tea.KeyUp:
+		if e.row > 0 {
+			e.row--
+		}
+	case tea.KeyDown:
+		if e.row < len(e.lines)-1 {
+			e.row++
+		}
+	case tea.KeyLeft:
+		if e.col > 0 {
+			e.col--
+		} else if e.row > 0 {
+			e.row--
+			e.col = len(e.lines[e.row])
+		}
+	case tea.KeyRight:
+		if e.col < len(line) {
+			e.col++
+		} else if e.row < len(e.lines)-1 {
+			e.row++
+			e.col = 0
+		}
+	case tea.KeyHome:
+		e.col = 0
+	case tea.KeyEnd:
+		e.col = len(line)
+	default:
+		return
+	}
+
+	if e.col > len(e.lines[e.row]) {
+		e.col = len(e.lines[e.row])
+	}
+	e.check()
+}
+
+// nodeState is what a node shows while a program runs or a trace is replayed.
+// line is -1 when the node has no code.
+type nodeState struct {
+	line int
+	acc  int16
+	bak  int16
+	mode emu.NodeMode
+}
+
+func newNodeState(n *emu.Node) *nodeState {
+	state := &nodeState{line: -1, acc: n.ACC, bak: n.BAK, mode: n.Mode()}
+	if len(n.Instructions) > 0 {
+		pos := int(n.CursorPosition)
+		if pos >= len(n.Instructions) {
+			pos = 0
+		}
+		state.line = n.LineNumbers[pos]
+	}
+	return state
+}
+
+// nodeHeat is the profile of a node shown as a heat map over its code.
+type nodeHeat struct {
+	hits   []int
+	max    int
+	modes  [4]int
+	cycles int
+}
+
+func newNodeHeat(np profile.NodeProfile, lines int, cycles int) *nodeHeat {
+	heat := &nodeHeat{hits: np.LineHits(lines), modes: np.Modes, cycles: max(cycles, 1)}
+	for _, hits := range heat.hits {
+		heat.max = max(heat.max, hits)
+	}
+	return heat
+}
+
+// level returns the heat level of a line, -1 for lines never executed.
+func (h *nodeHeat) level(line int, levels int) int {
+	if h == nil || line >= len(h.hits) || h.hits[line] == 0 {
+		return -1
+	}
+	return (h.hits[line]*levels - 1) / h.max
+}
+
+func (h *nodeHeat) percent(mode emu.NodeMode) int {
+	return h.modes[mode] * 100 / h.cycles
+}
+
+func (e nodeEditor) view(s styles, focused bool, state *nodeState, heat *nodeHeat) string {
+	if e.damaged {
+		return s.Damaged.Render("\n\n\n\n\n  COMMUNICATION\n     FAILURE")
+	}
+
+	current := -1
+	if state != nil {
+		current = state.line
+	}
+
+	rows := make([]string, 0, constants.MaxNodeLines+2)
+	for i, line := range e.lines {
+		cursor := -1
+		if focused && state == nil && i == e.row {
+			cursor = e.col
+		}
+		if i == current {
+			rows = append(rows, s.Cursor.Render(fmt.Sprintf("%-*s", constants.MaxLineLength+1, line)))
+		} else {
+			rows = append(rows, s.highlight(line, cursor, e.lineError(i) != nil, heat.level(i, len(s.Heat))))
+		}
+	}
+	for len(rows) < constants.MaxNodeLines {
+		rows = append(rows, "")
+	}
+	if state != nil {
+		rows = append(rows,
+			s.Status.Render(fmt.Sprintf("MODE %s", state.mode)),
+			s.Status.Render(fmt.Sprintf("ACC %4d  BAK %4d", state.acc, state.bak)),
+		)
+	} else if heat != nil {
+		rows = append(rows,
+			s.Status.Render(fmt.Sprintf("RUN %3d%% READ %3d%%", heat.percent(emu.RUN), heat.percent(emu.READ))),
+			s.Status.Render(fmt.Sprintf("WRTE %3d%% MAX %4d", heat.percent(emu.WRTE), heat.max)),
+		)
+	} else {
+		rows = append(rows, "")
+	}
+
+	if focused {
+		return s.FocusedNode.Render(strings.Join(rows, "\n"))
+	}
+	return s.Node.Render(strings.Join(rows, "\n"))
+}
+
+func newEditors(puzzle *types.Puzzle, code *types.ProgramCode) []nodeEditor {
+	editors := make([]nodeEditor, 0, len(puzzle.Layout))
+	for i, nodeType := range puzzle.Layout {
+		var nodeCode []string
+		if code != nil && i < len(code.NodesCode) {
+			nodeCode = code.NodesCode[i]
+		}
+		editors = append(editors, newNodeEditor(nodeType, nodeCode))
+	}
+	return editors
+}
+
+func solutionPath(dir string, puzzle *types.Puzzle) string {
+	return filepath.Join(dir, fmt.Sprintf("%s.tis", strings.ToLower(puzzle.Title)))
+}
+
+func fetchSolution(dir string, puzzle *types.Puzzle) *types.ProgramCode {
+	path := solutionPath(dir, puzzle)
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	code, err := parser.FetchCode(path)
+	if err != nil {
+		return nil
+	}
+	return code
+}
+
+func (m model) programCode() *types.ProgramCode {
+	nodesCode := make([][]string, 0, len(m.editors))
+	for _, e := range m.editors {
+		nodesCode = append(nodesCode, e.code())
+	}
+	return &types.ProgramCode{
+		Title:     m.puzzle.Title,
+		NodesCode: nodesCode,
+	}
+}
+
+func (m *model) moveFocus(step int) {
+	for range m.editors {
+		m.focus = (m.focus + step + len(m.editors)) % len(m.editors)
+		if !m.editors[m.focus].damaged {
+			return
+		}
+	}
+}
+
+func (m model) updateEditor(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case clearErrMsg:
+		m.status = ""
+	case tickMsg:
+		return m.updateRun(msg)
+	case testsDoneMsg:
+		return m.updateTests(msg)
+	case tea.KeyMsg:
+		switch {
+		case m.typing(msg):
+			return m.edit(msg)
+		case key.Matches(msg, m.keys.Run):
+			return m, m.toggleRun(false)
+		case key.Matches(msg, m.keys.Fast):
+			return m, m.toggleRun(true)
+		case key.Matches(msg, m.keys.Restart):
+			m.stopProgram()
+		case key.Matches(msg, m.keys.Profile):
+			return m, m.toggleHeat()
+		case key.Matches(msg, m.keys.Next):
+			m.moveFocus(1)
+		case key.Matches(msg, m.keys.Prev):
+			m.moveFocus(-1)
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		case key.Matches(msg, m.keys.Open):
+			m.stopProgram()
+			m.heat = nil
+			m.puzzlePath = ""
+			m.closePuzzle()
+			m.puzzle = nil
+			m.sandbox = false
+			m.editors = nil
+			m.browser.refreshScores(m.saveDir)
+		case key.Matches(msg, m.keys.Save):
+			path, err := parser.SaveCode(m.saveDir, m.programCode())
+			if err != nil {
+				m.status = err.Error()
+			} else {
+				m.status = "saved to " + path
+			}
+			return m, clearErrorAfter(statusTimeout)
+		default:
+			return m.edit(msg)
+		}
+	}
+
+	return m, nil
+}
+
+// typing reports whether msg is text typed into the focused node or into the
+// input of a running sandbox. Printable key bindings do not apply then.
+func (m model) typing(msg tea.KeyMsg) bool {
+	if msg.Type != tea.KeyRunes && msg.Type != tea.KeySpace {
+		return false
+	}
+	if m.puzzle == nil || m.replay != nil || len(m.editors) == 0 {
+		return false
+	}
+	return !m.running || m.sandbox
+}
+
+func (m model) edit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.running && m.sandbox {
+		return m.updateInput(msg)
+	}
+	if len(m.editors) > 0 && !m.running {
+		before := strings.Join(m.editors[m.focus].lines, "\n")
+		m.editors[m.focus].update(msg)
+		if strings.Join(m.editors[m.focus].lines, "\n") != before {
+			m.heat = nil
+			m.analyze()
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewEditor() string {
+	title := m.styles.Title.Render(m.puzzle.Title)
+	description := strings.Join(m.puzzle.Description, "\n")
+
+	rows := make([]string, 0)
+	for r := 0; r < len(m.editors); r += m.puzzle.Width {
+		cols := make([]string, 0, m.puzzle.Width)
+		for i := r; i < r+m.puzzle.Width && i < len(m.editors); i++ {
+			cols = append(cols, m.editors[i].view(m.styles, i == m.focus, m.nodeState(i), m.nodeHeat(i)))
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, cols...))
+	}
+	grid := lipgloss.JoinVertical(lipgloss.Left, rows...)
+
+	views := []string{
+		title,
+		description,
+		m.viewStreams(constants.INPUT),
+		grid,
+		m.viewStreams(constants.OUTPUT),
+	}
+	if m.puzzle.Connections != nil {
+		views = append(views, m.viewConnections())
+	}
+	if m.replay != nil {
+		views = append(views, m.viewReplay())
+	} else if m.running {
+		views = append(views, m.viewOutputs())
+	}
+	if m.replay != nil {
+		views = append(views, m.viewStatus(), m.help.View(replayKeyMap{m.keys}))
+	} else {
+		views = append(views, m.viewStatus(), m.help.View(m.keys))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, views...)
+}
+
+func (m model) nodeState(i int) *nodeState {
+	if m.replay != nil {
+		state := m.replay.Cycles[m.frame].Nodes[i]
+		return &nodeState{line: int(state.Line), acc: state.ACC, bak: state.BAK, mode: state.Mode}
+	}
+	if m.running {
+		return newNodeState(m.program.Nodes[i])
+	}
+	return nil
+}
+
+func (m model) nodeHeat(i int) *nodeHeat {
+	if m.heat == nil || len(m.heat.Nodes[i].Hits) == 0 {
+		return nil
+	}
+	return newNodeHeat(m.heat.Nodes[i], len(m.editors[i].lines), m.heat.Cycles)
+}
+
+// toggleHeat profiles the code on the streams of the puzzle to show it as a
+// heat map, or hides the heat map.
+func (m *model) toggleHeat() tea.Cmd {
+	if m.heat != nil {
+		m.heat = nil
+		return nil
+	}
+	prof, err := profile.Run(m.puzzle, m.puzzle.Streams, m.runCode(), constants.MaxCycles)
+	if err != nil {
+		m.status = err.Error()
+		return clearErrorAfter(statusTimeout)
+	}
+	m.heat = prof
+	m.status = fmt.Sprintf("profiled %d cycles: %s", prof.Cycles, prof.Result)
+	return clearErrorAfter(statusTimeout)
+}
+
+func (m model) viewStreams(streamType types.StreamType) string {
+	width := lipgloss.Width(m.styles.Node.Render(""))
+	cols := make([]string, m.puzzle.Width)
+	for i := range cols {
+		cols[i] = strings.Repeat(" ", width)
+	}
+	for _, stream := range m.puzzle.Streams {
+		if stream.Type == streamType && int(stream.Position) < len(cols) {
+			name := stream.Name
+			if streamType == constants.INPUT {
+				name += " ↓"
+			} else {
+				name += " ↑"
+			}
+			cols[stream.Position] = lipgloss.PlaceHorizontal(width, lipgloss.Center, name)
+		}
+	}
+	return strings.Join(cols, "")
+}
+
+// viewConnections lists the connections of puzzles that are not wired as a
+// grid, as the grid view can not show them.
+func (m model) viewConnections() string {
+	ports := []string{"UP", "RIGHT", "DOWN", "LEFT"}
+	links := make([]string, 0, len(m.puzzle.Connections))
+	for _, c := range m.puzzle.Connections {
+		arrow := "<->"
+		if c.OneWay {
+			arrow = "->"
+		}
+		links = append(links, fmt.Sprintf("%d %s %s %d %s", c.From, ports[c.FromPort], arrow, c.To, ports[c.ToPort]))
+	}
+	return m.styles.Status.Render("LINKS: " + strings.Join(links, ", "))
+}
+
+func (m model) viewStatus() string {
+	if m.status != "" {
+		return m.styles.Status.Render(m.status)
+	}
+	if len(m.editors) == 0 {
+		return ""
+	}
+
+	e := m.editors[m.focus]
+	err := e.lineError(e.row)
+	if err == nil && len(e.errs) > 0 {
+		err = e.errs[0]
+	}
+	if err == nil && len(e.warns) > 0 {
+		err = e.warns[0]
+	}
+	if err == nil {
+		return ""
+	}
+	diag := *err
+	diag.Node = uint8(m.focus)
+	if diag.Severity == emu.WARNING {
+		return m.styles.Warning.Render(diag.Error())
+	}
+	return m.styles.Error.Render(diag.Error())
+}
+
+// analyze runs the static analyzer over the code of every node to show its
+// warnings in the editors.
+func (m *model) analyze() {
+	for i := range m.editors {
+		m.editors[i].warns = nil
+	}
+	diags, err := analyzer.Analyze(m.puzzle, m.runCode())
+	if err != nil {
+		return
+	}
+	for _, diag := range diags {
+		e := &m.editors[diag.Node]
+		e.warns = append(e.warns, diag)
+	}
+}
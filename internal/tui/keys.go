@@ -1,14 +1,23 @@
 package tui
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
 
 type keyMap struct {
+	Up      key.Binding
+	Down    key.Binding
+	Select  key.Binding
 	Open    key.Binding
 	Save    key.Binding
 	Next    key.Binding
 	Prev    key.Binding
 	Run     key.Binding
+	Fast    key.Binding
 	Restart key.Binding
+	Profile key.Binding
 	Help    key.Binding
 	Quit    key.Binding
 }
@@ -19,43 +28,51 @@ func (k keyMap) ShortHelp() []key.Binding {
 
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Open, k.Save, k.Restart},
-		{k.Next, k.Prev, k.Run},
+		{k.Open, k.Save, k.Restart, k.Profile},
+		{k.Next, k.Prev, k.Run, k.Fast},
+		{k.Up, k.Down, k.Select},
+		{k.Help, k.Quit},
+	}
+}
+
+// replayKeyMap describes the keys that scrub through a replayed trace.
+type replayKeyMap struct {
+	keyMap
+}
+
+func (k replayKeyMap) FullHelp() [][]key.Binding {
+	rename := func(b key.Binding, desc string) key.Binding {
+		b.SetHelp(b.Help().Key, desc)
+		return b
+	}
+	return [][]key.Binding{
+		{rename(k.Next, "next cycle"), rename(k.Prev, "previous cycle"), rename(k.Fast, "skip cycles")},
+		{rename(k.Run, "play/pause trace"), rename(k.Restart, "go to first cycle")},
 		{k.Help, k.Quit},
 	}
 }
 
-var keys = keyMap{
-	Open: key.NewBinding(
-		key.WithKeys("ctrl+o"),
-		key.WithHelp("ctrl+o", "open puzzle"),
-	),
-	Save: key.NewBinding(
-		key.WithKeys("ctrl+s"),
-		key.WithHelp("ctrl+s", "save your solution"),
-	),
-	Next: key.NewBinding(
-		key.WithKeys("tab"),
-		key.WithHelp("tab", "move to next mode"),
-	),
-	Prev: key.NewBinding(
-		key.WithKeys("shift+tab"),
-		key.WithHelp("shift+tab", "move to previous node"),
-	),
-	Run: key.NewBinding(
-		key.WithKeys("ctrl+r"),
-		key.WithHelp("ctrl+r", "run program"),
-	),
-	Restart: key.NewBinding(
-		key.WithKeys("ctrl+n"),
-		key.WithHelp("ctrl+n", "restart program"),
-	),
-	Help: key.NewBinding(
-		key.WithKeys("?"),
-		key.WithHelp("?", "toggle help"),
-	),
-	Quit: key.NewBinding(
-		key.WithKeys("ctrl+c"),
-		key.WithHelp("ctrl+c", "quit"),
-	),
+func newKeyMap(bindings map[string][]string) keyMap {
+	binding := func(action string, desc string) key.Binding {
+		return key.NewBinding(
+			key.WithKeys(bindings[action]...),
+			key.WithHelp(strings.Join(bindings[action], "/"), desc),
+		)
+	}
+
+	return keyMap{
+		Up:      binding("up", "previous puzzle"),
+		Down:    binding("down", "next puzzle"),
+		Select:  binding("select", "open selected puzzle"),
+		Open:    binding("open", "open puzzle"),
+		Save:    binding("save", "save your solution"),
+		Next:    binding("next", "move to next node"),
+		Prev:    binding("prev", "move to previous node"),
+		Run:     binding("run", "run/pause program"),
+		Fast:    binding("fast", "run program fast"),
+		Restart: binding("restart", "stop program"),
+		Profile: binding("profile", "show/hide heat map"),
+		Help:    binding("help", "toggle help"),
+		Quit:    binding("quit", "quit"),
+	}
 }
@@ -1,79 +1,193 @@
 package tui
 
 import (
-	"os"
+	"time"
 
-	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/FranChesK0/tis-100/internal/config"
 	"github.com/FranChesK0/tis-100/internal/emu"
 	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/profile"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/trace"
 	"github.com/FranChesK0/tis-100/internal/types"
 )
 
+const statusTimeout = 2 * time.Second
+
 type model struct {
-	filepicker filepicker.Model
-	help       help.Model
+	browser browser
+	help    help.Model
 
 	puzzle  *types.Puzzle
 	program *emu.Program
+	editors []nodeEditor
 
+	config     *config.Config
 	keys       keyMap
+	styles     styles
 	puzzlePath string
-	running    bool
+	saveDir    string
+	focus      int
+	status     string
+	width      int
+	height     int
+	recorder   *recorder
+
+	sandbox bool
+	input   string
+	sent    []int16
 
-	filepickerErr  error
-	fetchPuzzleErr error
+	running bool
+	outputs []types.Stream
+	paused  bool
+	fast    bool
+	cycles  int
+	runID   int
+	result  string
+	tests   []runner.TestResult
+	heat    *profile.Profile
+
+	replay *trace.Trace
+	frame  int
 }
 
-func NewModel() (*model, error) {
-	var err error
+type Options struct {
+	Sandbox bool
+	Trace   *trace.Trace
+	// Solution is the code the sandbox opens with instead of the saved one.
+	Solution *types.ProgramCode
+	// Record is an asciicast file the runs of programs are recorded to.
+	Record string
+}
 
-	fp := filepicker.New()
-	fp.AllowedTypes = []string{".lua"}
-	fp.CurrentDirectory, err = os.Getwd()
-	if err != nil {
-		return &model{}, nil
+func NewModel(cfg *config.Config, opts Options) (*model, error) {
+	m := &model{
+		config:  cfg,
+		keys:    newKeyMap(cfg.Keys),
+		styles:  newStyles(cfg.CurrentTheme()),
+		help:    help.New(),
+		browser: newBrowser(cfg.SaveDir),
+		program: emu.NewProgram(),
+		saveDir: cfg.SaveDir,
 	}
-	return &model{
-		keys:       keys,
-		help:       help.New(),
-		filepicker: fp,
-		program:    emu.NewProgram(),
-	}, nil
+	if opts.Sandbox {
+		m.openSandbox(opts.Solution)
+	} else if opts.Trace != nil {
+		m.openTrace(opts.Trace)
+	}
+	return m, nil
 }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tea.SetWindowTitle("TIS-100"), m.filepicker.Init())
+	return tea.Batch(tea.SetWindowTitle("TIS-100"), loadSegments(m.config.PuzzleDir, puzzleOptions(m.config)))
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.help.Width = msg.Width
+		if m.recorder != nil {
+			m.recorder.resize(msg.Width, msg.Height)
+		}
+	case segmentsLoadedMsg:
+		m.browser.loading = false
+		m.browser.err = msg.err
+		m.browser.setSegments(msg.segments)
+		return m, nil
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
+		if key.Matches(msg, m.keys.Quit) && !m.typing(msg) {
 			return m, tea.Quit
 		}
 	}
 
 	if m.puzzlePath == "" {
-		return m.updateFilepicker(msg)
+		return m.updateBrowser(msg)
 	} else if m.puzzle == nil {
-		m.puzzle, m.fetchPuzzleErr = parser.FetchPuzzle(m.puzzlePath)
+		return m.updateLoading(msg)
+	} else if m.replay != nil {
+		return m.updateReplay(msg)
 	}
 
+	return m.updateEditor(msg)
+}
+
+// updateLoading waits for the selected puzzle, going back to the browser if
+// its script fails.
+func (m model) updateLoading(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case puzzleLoadedMsg:
+		if msg.path != m.puzzlePath {
+			if msg.err == nil {
+				msg.puzzle.Close()
+			}
+			return m, nil
+		}
+		if msg.err != nil {
+			m.puzzlePath = ""
+			m.status = msg.err.Error()
+			return m, clearErrorAfter(statusTimeout)
+		}
+		m.puzzle = msg.puzzle
+		m.openEditors()
+	}
 	return m, nil
 }
 
+func puzzleOptions(cfg *config.Config) parser.PuzzleOptions {
+	return parser.PuzzleOptions{Trusted: cfg.Trusted}
+}
+
+func (m *model) openEditors() {
+	m.openCode(fetchSolution(m.saveDir, m.puzzle))
+}
+
+func (m *model) openCode(code *types.ProgramCode) {
+	m.editors = newEditors(m.puzzle, code)
+	m.analyze()
+	m.focus = 0
+	if m.editors[m.focus].damaged {
+		m.moveFocus(1)
+	}
+}
+
+// closePuzzle frees the puzzle being solved unless the browser holds it, as it
+// does with puzzles of packs.
+func (m *model) closePuzzle() {
+	if m.puzzle == nil {
+		return
+	}
+	for i := range m.browser.items {
+		if m.browser.entry(i).Puzzle == m.puzzle {
+			return
+		}
+	}
+	m.puzzle.Close()
+}
+
+// openSandbox opens the sandbox with code, or with the saved code of the
+// sandbox if code is nil.
+func (m *model) openSandbox(code *types.ProgramCode) {
+	m.puzzlePath = runner.SandboxTitle
+	m.puzzle = runner.SandboxPuzzle()
+	m.sandbox = true
+	if code == nil {
+		code = fetchSolution(m.saveDir, m.puzzle)
+	}
+	m.openCode(code)
+}
+
 func (m model) View() string {
 	if m.puzzlePath == "" {
-		return m.viewFilepicker()
+		return m.viewBrowser()
 	} else if m.puzzle == nil {
 		return "Loading puzzle..."
-	} else if m.fetchPuzzleErr != nil {
-		return "Error while fetching puzzle: " + m.fetchPuzzleErr.Error()
 	} else {
-		return "Puzzle title: " + m.puzzle.Title
+		return m.viewEditor()
 	}
 }
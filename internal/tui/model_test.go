@@ -0,0 +1,31 @@
+package tui
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/config"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+/* TESTS */
+
+// NewModel
+func TestNewModelWithSandboxSolution(t *testing.T) {
+	cfg := config.Default()
+	cfg.SaveDir = t.TempDir()
+	code := &types.ProgramCode{NodesCode: [][]string{{"MOV UP, DOWN"}, nil, {"ADD 1", "NEG"}}}
+
+	m, err := NewModel(cfg, Options{Sandbox: true, Solution: code})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for i, nodeCode := range code.NodesCode {
+		if len(nodeCode) == 0 {
+			continue
+		}
+		if got := m.editors[i].lines; !reflect.DeepEqual(got, nodeCode) {
+			t.Errorf("wrong code of node %d. expected: %q, got: %q", i, nodeCode, got)
+		}
+	}
+}
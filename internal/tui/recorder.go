@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorder passes the output of the TUI to the terminal and records it to an
+// asciicast v2 file while a program runs. The clock of the recording stops
+// between runs, so runs are played back to back.
+type recorder struct {
+	*os.File
+
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	started bool
+	active  bool
+	elapsed time.Duration
+	since   time.Time
+	pending []byte
+	err     error
+}
+
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title"`
+}
+
+func newRecorder(file string, out *os.File) (*recorder, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file with name %s: %w", file, err)
+	}
+	return &recorder{File: out, file: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active {
+		// a rune split between writes is recorded with the next write
+		data := append(r.pending, p...)
+		end := len(data)
+		if i := lastRuneStart(data); !utf8.FullRune(data[i:]) {
+			end = i
+		}
+		r.pending = append([]byte{}, data[end:]...)
+		r.event("o", string(data[:end]))
+	}
+	return r.File.Write(p)
+}
+
+func lastRuneStart(data []byte) int {
+	i := len(data) - 1
+	for i > 0 && i > len(data)-utf8.UTFMax && !utf8.RuneStart(data[i]) {
+		i--
+	}
+	return max(i, 0)
+}
+
+// Close finishes the recording, the terminal stays open.
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		return fmt.Errorf("unable to record to %s: %w", r.file.Name(), r.err)
+	}
+	return nil
+}
+
+// resume starts recording frames, writing the header of the recording with the
+// size of the terminal the first time.
+func (r *recorder) resume(width int, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started {
+		r.started = true
+		r.encode(castHeader{Version: 2, Width: width, Height: height, Timestamp: time.Now().Unix(), Title: "TIS-100"})
+	}
+	if !r.active {
+		r.active = true
+		r.since = time.Now()
+	}
+}
+
+func (r *recorder) pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active {
+		r.active = false
+		r.elapsed += time.Since(r.since)
+		r.pending = nil
+	}
+}
+
+func (r *recorder) resize(width int, height int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.active {
+		r.event("r", fmt.Sprintf("%dx%d", width, height))
+	}
+}
+
+func (r *recorder) event(code string, data string) {
+	if data == "" {
+		return
+	}
+	seconds := (r.elapsed + time.Since(r.since)).Seconds()
+	r.encode([]any{math.Round(seconds*1e6) / 1e6, code, data})
+}
+
+func (r *recorder) encode(v any) {
+	if r.err != nil {
+		return
+	}
+	line, err := json.Marshal(v)
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	r.err = err
+}
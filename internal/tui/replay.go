@@ -0,0 +1,113 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/trace"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+// openTrace shows a recorded run in place of a puzzle. The run is scrubbed
+// through cycle by cycle or played back without running the emulator.
+func (m *model) openTrace(t *trace.Trace) {
+	m.replay = t
+	m.frame = 0
+	m.paused = true
+	m.puzzlePath = t.Title
+	m.puzzle = &types.Puzzle{
+		Title:       t.Title,
+		Description: []string{fmt.Sprintf("TRACE OF %d CYCLES: %s", len(t.Cycles)-1, strings.ToUpper(t.Result))},
+		Streams:     t.Streams,
+		Width:       t.Width,
+		Height:      t.Height,
+		Layout:      t.Layout,
+	}
+	m.editors = newEditors(m.puzzle, &t.Code)
+	m.focus = 0
+}
+
+func (m *model) setFrame(frame int) {
+	m.frame = max(0, min(frame, len(m.replay.Cycles)-1))
+}
+
+func (m model) updateReplay(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		if msg.run != m.runID || m.paused {
+			return m, nil
+		}
+		if m.frame < len(m.replay.Cycles)-1 {
+			m.frame++
+			return m, m.tick()
+		}
+		m.paused = true
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.keys.Run):
+			m.paused = !m.paused
+			if !m.paused {
+				m.runID++
+				return m, m.tick()
+			}
+		case key.Matches(msg, m.keys.Fast):
+			m.setFrame(m.frame + m.config.FastCycles)
+		case key.Matches(msg, m.keys.Next):
+			m.setFrame(m.frame + 1)
+		case key.Matches(msg, m.keys.Prev):
+			m.setFrame(m.frame - 1)
+		case key.Matches(msg, m.keys.Restart):
+			m.paused = true
+			m.setFrame(0)
+		case key.Matches(msg, m.keys.Help):
+			m.help.ShowAll = !m.help.ShowAll
+		}
+	}
+	return m, nil
+}
+
+func (m model) viewReplay() string {
+	lines := make([]string, 0)
+	for i, stream := range m.replay.Streams {
+		if stream.Type != constants.OUTPUT {
+			continue
+		}
+		received := m.replay.Received(i, m.frame)
+		values := make([]string, 0, len(received))
+		for j, value := range received {
+			str := fmt.Sprint(value)
+			if j >= len(stream.Values) || value != stream.Values[j] {
+				str = m.styles.Error.Render(str)
+			}
+			values = append(values, str)
+		}
+		lines = append(lines, fmt.Sprintf(
+			"%s %d/%d: %s",
+			stream.Name,
+			len(received),
+			len(stream.Values),
+			strings.Join(values, " "),
+		))
+	}
+
+	transfers := make([]string, 0)
+	for _, t := range m.replay.Cycles[m.frame].Transfers {
+		transfers = append(transfers, fmt.Sprintf("%s -> %s: %d", m.replay.NodeName(t.From), m.replay.NodeName(t.To), t.Value))
+	}
+	if len(transfers) > 0 {
+		lines = append(lines, "SENT "+strings.Join(transfers, ", "))
+	}
+
+	state := "PLAYING"
+	if m.frame == len(m.replay.Cycles)-1 {
+		state = strings.ToUpper(m.replay.Result)
+	} else if m.paused {
+		state = "PAUSED"
+	}
+	lines = append(lines, m.styles.Status.Render(fmt.Sprintf("CYCLE %d/%d - %s", m.frame, len(m.replay.Cycles)-1, state)))
+	return strings.Join(lines, "\n")
+}
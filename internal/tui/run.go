@@ -0,0 +1,286 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/parser"
+	"github.com/FranChesK0/tis-100/internal/runner"
+	"github.com/FranChesK0/tis-100/internal/types"
+)
+
+type tickMsg struct {
+	run int
+}
+
+func (m model) tick() tea.Cmd {
+	run := m.runID
+	return tea.Tick(m.config.TickRate.Duration, func(_ time.Time) tea.Msg { return tickMsg{run: run} })
+}
+
+func (m model) runCode() types.ProgramCode {
+	nodesCode := make([][]string, 0, len(m.editors))
+	for _, e := range m.editors {
+		nodesCode = append(nodesCode, e.lines)
+	}
+	return types.ProgramCode{
+		Title:     m.puzzle.Title,
+		NodesCode: nodesCode,
+	}
+}
+
+func (m *model) startProgram(fast bool) tea.Cmd {
+	p, err := runner.NewProgram(m.puzzle)
+	if err != nil {
+		m.status = err.Error()
+		return clearErrorAfter(statusTimeout)
+	}
+	if err := p.LoadStreams(m.puzzle.Streams); err != nil {
+		m.status = err.Error()
+		return clearErrorAfter(statusTimeout)
+	}
+	if err := p.LoadCode(m.runCode()); err != nil {
+		m.status = err.Error()
+		return clearErrorAfter(statusTimeout)
+	}
+
+	m.program = p
+	m.outputs = runner.OutputStreams(m.puzzle.Streams)
+	m.running = true
+	m.paused = false
+	m.fast = fast
+	m.cycles = 0
+	m.result = ""
+	m.input = ""
+	m.sent = nil
+	m.tests = nil
+	m.runID++
+	if m.recorder != nil {
+		// the recording starts with a full frame instead of changed lines
+		m.recorder.resume(m.width, m.height)
+		return tea.Batch(m.tick(), tea.ClearScreen)
+	}
+	return m.tick()
+}
+
+func (m *model) stopProgram() {
+	if m.recorder != nil {
+		m.recorder.pause()
+	}
+	m.running = false
+	m.paused = false
+	m.result = ""
+	m.runID++
+}
+
+func (m model) updateRun(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		if msg.run != m.runID || m.paused {
+			return m, nil
+		}
+
+		cycles := 1
+		if m.fast {
+			cycles = m.config.FastCycles
+		}
+		for range cycles {
+			if _, err := m.program.Tick(); err != nil {
+				m.paused = true
+				m.result = err.Error()
+				return m, nil
+			}
+			m.cycles++
+			if m.sandbox {
+				continue
+			}
+
+			verdict, reason, err := runner.Verify(m.puzzle.Validator, m.outputs, m.program.Outputs)
+			if err != nil {
+				m.paused = true
+				m.result = err.Error()
+				return m, nil
+			}
+			switch verdict {
+			case runner.FAILED:
+				m.paused = true
+				m.result = strings.ToUpper(reason)
+				return m, nil
+			case runner.PASSED:
+				m.paused = true
+				return m, m.finishRun()
+			}
+		}
+		return m, m.tick()
+	}
+
+	return m, nil
+}
+
+func (m *model) toggleRun(fast bool) tea.Cmd {
+	if !m.running {
+		return m.startProgram(fast)
+	}
+	if m.result != "" {
+		return nil
+	}
+	if m.paused || m.fast != fast {
+		m.paused = false
+		m.fast = fast
+		m.runID++
+		return m.tick()
+	}
+	m.paused = true
+	return nil
+}
+
+func (m model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyRunes:
+		for _, r := range msg.Runes {
+			if (r < '0' || r > '9') && r != '-' {
+				return m, nil
+			}
+		}
+		if len(m.input)+len(msg.Runes) <= len(fmt.Sprint(constants.MinACC)) {
+			m.input += string(msg.Runes)
+		}
+	case tea.KeyBackspace:
+		if len(m.input) > 0 {
+			m.input = m.input[:len(m.input)-1]
+		}
+	case tea.KeyEnter:
+		value, err := runner.ParseValue(m.input)
+		if err == nil {
+			err = m.program.PushInput(0, value)
+		}
+		if err != nil {
+			m.status = err.Error()
+			return m, clearErrorAfter(statusTimeout)
+		}
+		m.sent = append(m.sent, value)
+		m.input = ""
+	}
+	return m, nil
+}
+
+// testsDoneMsg carries the results of the tests of the run with the given id.
+type testsDoneMsg struct {
+	run     int
+	results []runner.TestResult
+	err     error
+}
+
+// finishRun records the score once the visible streams pass. The tests of
+// puzzles that have them are run first, outside of Update as they may take a
+// while.
+func (m *model) finishRun() tea.Cmd {
+	if len(m.puzzle.Tests) == 0 {
+		m.result = fmt.Sprintf("PASSED IN %d CYCLES", m.cycles)
+		return m.saveScore(runner.Measure(m.program, m.cycles))
+	}
+
+	m.result = "RUNNING TESTS..."
+	run, puzzle, code := m.runID, m.puzzle, m.runCode()
+	return func() tea.Msg {
+		results, err := runner.RunTests(puzzle, code, constants.MaxCycles)
+		return testsDoneMsg{run: run, results: results, err: err}
+	}
+}
+
+func (m model) updateTests(msg testsDoneMsg) (tea.Model, tea.Cmd) {
+	if msg.run != m.runID {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.result = msg.err.Error()
+		return m, nil
+	}
+	m.tests = msg.results
+
+	passed, score := runner.Summarize(msg.results)
+	if !passed {
+		m.result = "FAILED HIDDEN TESTS"
+		return m, nil
+	}
+	m.result = fmt.Sprintf("PASSED %d TESTS IN %d CYCLES", len(msg.results), score.Cycles)
+	return m, m.saveScore(score)
+}
+
+func (m *model) saveScore(score types.Score) tea.Cmd {
+	if err := m.recordScore(score); err != nil {
+		m.status = err.Error()
+		return clearErrorAfter(statusTimeout)
+	}
+	return nil
+}
+
+func (m *model) recordScore(score types.Score) error {
+	scores, err := parser.FetchScores(m.saveDir)
+	if err != nil {
+		return err
+	}
+	key := scoreKey(m.config.PuzzleDir, m.puzzlePath)
+	scores[key] = runner.Best(scores[key], score)
+	return parser.SaveScores(m.saveDir, scores)
+}
+
+func (m model) viewOutputs() string {
+	lines := make([]string, 0)
+	for i, stream := range m.outputs {
+		produced := m.program.Outputs[i].Values
+		values := make([]string, 0, len(produced))
+		for j, value := range produced {
+			str := fmt.Sprint(value)
+			exact := !m.sandbox && m.puzzle.Validator == nil
+			if exact && (j >= len(stream.Values) || value != stream.Values[j]) {
+				str = m.styles.Error.Render(str)
+			}
+			values = append(values, str)
+		}
+		if m.sandbox {
+			lines = append(lines, fmt.Sprintf("%s: %s", stream.Name, strings.Join(values, " ")))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			"%s %d/%d: %s",
+			stream.Name,
+			len(produced),
+			len(stream.Values),
+			strings.Join(values, " "),
+		))
+	}
+	if m.sandbox {
+		sent := make([]string, 0, len(m.sent))
+		for _, value := range m.sent {
+			sent = append(sent, fmt.Sprint(value))
+		}
+		lines = append([]string{
+			fmt.Sprintf("%s: %s", m.puzzle.Streams[0].Name, strings.Join(sent, " ")),
+			"> " + m.input + m.styles.Cursor.Render(" "),
+		}, lines...)
+	}
+
+	for _, test := range m.tests {
+		line := fmt.Sprintf("TEST %s: PASSED IN %d CYCLES", test.Name, test.Score.Cycles)
+		if !test.Passed {
+			line = m.styles.Error.Render(fmt.Sprintf("TEST %s: %s", test.Name, strings.ToUpper(test.Reason)))
+		}
+		lines = append(lines, line)
+	}
+
+	state := "RUNNING"
+	if m.result != "" {
+		state = m.result
+	} else if m.paused {
+		state = "PAUSED"
+	} else if m.fast {
+		state = "FAST"
+	}
+	lines = append(lines, m.styles.Status.Render(fmt.Sprintf("CYCLE %d - %s", m.cycles, state)))
+	return strings.Join(lines, "\n")
+}
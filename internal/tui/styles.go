@@ -0,0 +1,148 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/FranChesK0/tis-100/internal/config"
+	"github.com/FranChesK0/tis-100/internal/constants"
+	"github.com/FranChesK0/tis-100/internal/emu"
+)
+
+type styles struct {
+	Text     lipgloss.Style
+	Opcode   lipgloss.Style
+	Register lipgloss.Style
+	Port     lipgloss.Style
+	Label    lipgloss.Style
+	Literal  lipgloss.Style
+	Comment  lipgloss.Style
+	Invalid  lipgloss.Style
+	Cursor   lipgloss.Style
+	Heat     []lipgloss.Style
+
+	Node        lipgloss.Style
+	FocusedNode lipgloss.Style
+	Damaged     lipgloss.Style
+	Title       lipgloss.Style
+	Error       lipgloss.Style
+	Warning     lipgloss.Style
+	Status      lipgloss.Style
+}
+
+func newStyles(theme config.Theme) styles {
+	base := lipgloss.NewStyle()
+	if theme.Background != "" {
+		base = base.Background(lipgloss.Color(theme.Background))
+	}
+	color := func(c string) lipgloss.Style {
+		return base.Foreground(lipgloss.Color(c))
+	}
+
+	node := color(theme.Text).
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color(theme.Border)).
+		Width(constants.MaxLineLength + 1).
+		Height(constants.MaxNodeLines + 2)
+	if theme.Background != "" {
+		node = node.BorderBackground(lipgloss.Color(theme.Background))
+	}
+
+	return styles{
+		Text:     color(theme.Text),
+		Opcode:   color(theme.Opcode).Bold(true),
+		Register: color(theme.Register),
+		Port:     color(theme.Port),
+		Label:    color(theme.Label),
+		Literal:  color(theme.Literal),
+		Comment:  color(theme.Comment).Italic(true),
+		Invalid:  color(theme.Invalid),
+		Cursor:   base.Reverse(true),
+		Heat:     heatStyles(base),
+
+		Node:        node,
+		FocusedNode: node.BorderForeground(lipgloss.Color(theme.Focus)).Border(lipgloss.ThickBorder()),
+		Damaged:     node.BorderForeground(lipgloss.Color(theme.Damaged)).Foreground(lipgloss.Color(theme.Damaged)),
+		Title:       color(theme.Text).Bold(true),
+		Error:       color(theme.Error),
+		Warning:     color(theme.Warning),
+		Status:      color(theme.Status),
+	}
+}
+
+func (s styles) token(t emu.TokenType) lipgloss.Style {
+	switch t {
+	case emu.OPCODE:
+		return s.Opcode
+	case emu.REGISTER:
+		return s.Register
+	case emu.PORT:
+		return s.Port
+	case emu.LABEL:
+		return s.Label
+	case emu.LITERAL:
+		return s.Literal
+	case emu.COMMENT:
+		return s.Comment
+	case emu.INVALID:
+		return s.Invalid
+	default:
+		return s.Text
+	}
+}
+
+// heatColors go from rarely to often executed lines.
+var heatColors = []string{"17", "18", "54", "90", "126", "162", "161", "160", "196"}
+
+func heatStyles(base lipgloss.Style) []lipgloss.Style {
+	heat := make([]lipgloss.Style, 0, len(heatColors))
+	for _, c := range heatColors {
+		heat = append(heat, base.Background(lipgloss.Color(c)))
+	}
+	return heat
+}
+
+// highlight renders a line of code using the emulator tokenizer. cursor is the
+// column to draw the cursor at, or -1 to draw no cursor. heat is the level of
+// the heat map to draw the line with, or -1 to draw no heat map.
+func (s styles) highlight(line string, cursor int, underline bool, heat int) string {
+	width := len(line)
+	if cursor >= width {
+		width = cursor + 1
+	}
+	cells := make([]lipgloss.Style, width)
+	for i := range cells {
+		cells[i] = s.Text
+	}
+	for _, tok := range emu.Tokenize(line) {
+		for i := tok.Start; i < tok.End; i++ {
+			cells[i] = s.token(tok.Type)
+		}
+	}
+	if underline {
+		for i := range len(line) {
+			cells[i] = cells[i].Underline(true)
+		}
+	}
+	if heat >= 0 {
+		// loaded solutions are not length checked, so lines may be longer
+		width = max(width, constants.MaxLineLength+1)
+		for len(cells) < width {
+			cells = append(cells, s.Text)
+		}
+		for i := range cells {
+			cells[i] = cells[i].Inherit(s.Heat[heat])
+		}
+	}
+	if cursor >= 0 {
+		cells[cursor] = cells[cursor].Inherit(s.Cursor)
+	}
+
+	padded := line + strings.Repeat(" ", width-len(line))
+	var b strings.Builder
+	for i, style := range cells {
+		b.WriteString(style.Render(padded[i : i+1]))
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/FranChesK0/tis-100/internal/config"
+	"github.com/FranChesK0/tis-100/internal/constants"
+)
+
+/* TESTS */
+
+// highlight
+func TestHighlightWithHeat(t *testing.T) {
+	s := newStyles(config.Default().CurrentTheme())
+	tests := []struct {
+		line   string
+		cursor int
+	}{
+		{"MOV UP, ACC", -1},
+		{"MOV UP, ACC # a comment past the line limit", -1},
+		{"MOV UP, ACC # a comment past the line limit", 50},
+		{"ADD 1", 30},
+	}
+	for _, test := range tests {
+		got := s.highlight(test.line, test.cursor, false, 0)
+		width := max(len(test.line), test.cursor+1, constants.MaxLineLength+1)
+		if n := len(stripStyles(got)); n != width {
+			t.Errorf("wrong width of %q. expected: %d, got: %d", test.line, width, n)
+		}
+	}
+}
+
+/* UTILS */
+// stripStyles removes the escape sequences lipgloss renders styles with.
+func stripStyles(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\x1b' {
+			for i < len(text) && text[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
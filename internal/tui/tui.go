@@ -1,15 +1,35 @@
 package tui
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	"errors"
+	"os"
 
-func ProgramRun() error {
-	m, err := NewModel()
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/FranChesK0/tis-100/internal/config"
+)
+
+func ProgramRun(opts Options) error {
+	cfg, err := config.Load()
 	if err != nil {
 		return err
 	}
-	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	m, err := NewModel(cfg, opts)
+	if err != nil {
 		return err
 	}
-	return nil
+	teaOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if opts.Record != "" {
+		if m.recorder, err = newRecorder(opts.Record, os.Stdout); err != nil {
+			return err
+		}
+		teaOpts = append(teaOpts, tea.WithOutput(m.recorder))
+	}
+
+	p := tea.NewProgram(m, teaOpts...)
+	_, err = p.Run()
+	if m.recorder != nil {
+		err = errors.Join(err, m.recorder.Close())
+	}
+	return err
 }
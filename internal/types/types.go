@@ -1,8 +1,11 @@
 package types
 
+import "io"
+
 type (
 	StreamType uint8
 	NodeType   uint8
+	Port       uint8
 )
 
 type Stream struct {
@@ -12,14 +15,78 @@ type Stream struct {
 	Values   []int16
 }
 
+// Connection links port FromPort of node From with port ToPort of node To.
+// Values of a one way connection only go from From to To.
+type Connection struct {
+	From     int
+	FromPort Port
+	To       int
+	ToPort   Port
+	OneWay   bool
+}
+
+type Test struct {
+	Name    string
+	Streams []Stream
+}
+
+// Validator decides whether the values produced for an output stream are
+// correct when they can not be compared with the expected values directly.
+// It returns the verdict and a message explaining a failure.
+type Validator interface {
+	Validate(streamName string, produced []int16, expected []int16) (bool, string, error)
+}
+
 type Puzzle struct {
 	Title       string
 	Description []string
 	Streams     []Stream
+	Tests       []Test
+	Width       int
+	Height      int
 	Layout      []NodeType
+	Connections []Connection
+	Validator   Validator
+}
+
+// Close frees what the validator of the puzzle holds, such as the state of a
+// Lua script. The puzzle can not be validated once closed.
+func (p *Puzzle) Close() error {
+	if closer, ok := p.Validator.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 type ProgramCode struct {
 	Title     string
 	NodesCode [][]string
 }
+
+type Score struct {
+	Cycles       int `json:"cycles"`
+	Nodes        int `json:"nodes"`
+	Instructions int `json:"instructions"`
+}
+
+type PuzzleEntry struct {
+	Path     string
+	Pack     string
+	Puzzle   *Puzzle
+	Solution *ProgramCode
+	Err      error
+}
+
+type Segment struct {
+	Name    string
+	Puzzles []PuzzleEntry
+}
+
+type Pack struct {
+	Path        string
+	Name        string
+	Version     string
+	Author      string
+	Description []string
+	Segments    []Segment
+}
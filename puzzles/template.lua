@@ -15,6 +15,29 @@ function GetDescription()
 	return { "DESCRIPTION LINE 1", "DESCRIPTION LINE 2" }
 end
 
+-- The function GetGrid is optional. It should return an array with two values:
+-- width and height of the grid of nodes, each between 1 and 12.
+-- Without it the grid is 4 nodes wide and 3 nodes high.
+--
+-- function GetGrid()
+-- 	return { 5, 5 }
+-- end
+
+-- The function GetConnections is optional. It replaces the grid wiring of the
+-- nodes with the returned array of connections. Each connection is described by
+-- an array with four or five values: node, port, node, port and an optional
+-- boolean that makes values go only from the first node to the second one.
+-- Nodes are numbered from 0 row by row and ports are "UP", "RIGHT", "DOWN" and
+-- "LEFT". A port can be used by one connection only, and the ports of streams
+-- must stay free.
+--
+-- function GetConnections()
+-- 	return {
+-- 		{ 0, "RIGHT", 1, "LEFT" },
+-- 		{ 3, "RIGHT", 0, "LEFT", true },
+-- 	}
+-- end
+
 -- The function GetStreams should return an array of streams.
 -- Each stream is described by an array with four values: STREAM_*, name, position
 -- and array of integer values between -999 and 999 inclusive.
@@ -22,9 +45,10 @@ end
 -- STREAM_INPUT: An input stream containing up to 30 numerical values.
 -- STREAM_OUTPUT: An output stream containing up to 30 numercial values.
 --
--- Position values should be between 0 and 3, which correspond to the far
--- left and far right of the TIS-100 segment grid. Input streams will be automatically
--- placed on the top, while output streams will be placed on the bottom.
+-- Position values should be between 0 and the grid width minus 1 (3 by default),
+-- which correspond to the far left and far right of the TIS-100 segment grid.
+-- Input streams will be automatically placed on the top, while output streams
+-- will be placed on the bottom.
 function GetStreams()
 	local input = {}
 	local output = {}
@@ -38,8 +62,45 @@ function GetStreams()
 	}
 end
 
--- The function GetLayout should return an array of exactly 12 TILE_* values,
--- which describe the layout and type of tiles in the puzzle.
+-- The function GetTests is optional. It should return an array of tests,
+-- where each test is described by an array with two values: name and array of
+-- streams in the same format as GetStreams. A solution passes the puzzle only
+-- if it passes every test, so tests are the place for edge cases like 0 or -999.
+-- If GetStreams is not defined, the streams of the first test are shown instead.
+function GetTests()
+	return {
+		{ "ZEROS", {
+			{ STREAM_INPUT, "IN", 0, { 0, 0, 0 } },
+			{ STREAM_OUTPUT, "OUT", 0, { 0, 0, 0 } },
+		} },
+		{ "NEGATIVE", {
+			{ STREAM_INPUT, "IN", 0, { -1, -25, -499 } },
+			{ STREAM_OUTPUT, "OUT", 0, { -2, -50, -998 } },
+		} },
+	}
+end
+
+-- The function Validate is optional. When it is defined, produced values are not
+-- compared with the expected ones directly. Instead, once every output stream has
+-- produced as many values as expected, Validate is called for each output stream
+-- with its name, an array of produced values and an array of expected values.
+-- It should return true if the values are correct, or false and a message
+-- that is shown to the player.
+--
+-- function Validate(name, produced, expected)
+-- 	table.sort(produced)
+-- 	table.sort(expected)
+-- 	for i = 1, #expected do
+-- 		if produced[i] ~= expected[i] then
+-- 			return false, "values of " .. name .. " do not match in any order"
+-- 		end
+-- 	end
+-- 	return true
+-- end
+
+-- The function GetLayout should return an array of exactly width * height
+-- (12 by default) TILE_* values, which describe the layout and type of tiles
+-- in the puzzle, row by row.
 --
 -- TILE_COMUPTE: A basic execution node.
 -- TILE_DAMAGED: A damaged execution node, which acts as an obstacle.